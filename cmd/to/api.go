@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/auth"
+	"github.com/textonlyio/textonly-cli/internal/jq"
+)
+
+func newAPICommand() *cobra.Command {
+	var (
+		rawFields []string
+		fields    []string
+		headers   []string
+		input     string
+		paginate  bool
+		include   bool
+		jqExpr    string
+	)
+	c := &cobra.Command{
+		Use:   "api [<method>] <path>",
+		Short: "Make an authenticated API request",
+		Long: `Make an authenticated request to any TextOnly API endpoint and print the response.
+
+The method defaults to GET, or POST when fields are given. Fields are sent as
+query parameters for GET/HEAD/DELETE and as a JSON object otherwise.
+
+  -f key=value   string field
+  -F key=value   typed field: true, false, null and integers are converted,
+                 @file reads the value from a file (@- for stdin)`,
+		Example: `  to api /me
+  to api GET /notes --paginate --jq '.[].title'
+  to api POST /notes -f title=Hello -F content=@note.txt`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			method, path := "", args[0]
+			if len(args) == 2 {
+				method, path = strings.ToUpper(args[0]), args[1]
+			}
+			params, err := parseFields(rawFields, fields)
+			if err != nil {
+				return err
+			}
+			if method == "" {
+				method = http.MethodGet
+				if len(params) > 0 && input == "" {
+					method = http.MethodPost
+				}
+			}
+			var query *jq.Query
+			if jqExpr != "" {
+				if query, err = jq.Parse(jqExpr); err != nil {
+					return err
+				}
+			}
+			header := http.Header{}
+			for _, h := range headers {
+				k, v, ok := strings.Cut(h, ":")
+				if !ok {
+					return fmt.Errorf("invalid header %q: expected key:value", h)
+				}
+				header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+			}
+
+			var body []byte
+			switch {
+			case input != "":
+				if body, err = readFileOrStdin(input); err != nil {
+					return err
+				}
+				path = withQuery(path, params)
+			case len(params) > 0 && (method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete):
+				path = withQuery(path, params)
+			case len(params) > 0:
+				if body, err = json.Marshal(params); err != nil {
+					return err
+				}
+			}
+			if body != nil && header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/json; charset=utf-8")
+			}
+
+			client := auth.NewClient()
+			for path != "" {
+				resp, err := client.Raw(cmd.Context(), method, path, header, body)
+				if err != nil {
+					return err
+				}
+				b, err := io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+				if err != nil {
+					return err
+				}
+				if include {
+					printHeaders(resp)
+				}
+				if resp.StatusCode >= 300 {
+					os.Stdout.Write(b)
+					if len(b) > 0 && b[len(b)-1] != '\n' {
+						fmt.Println()
+					}
+					return api.NewAPIError(resp.StatusCode, resp.Header, b)
+				}
+				if err := printBody(b, query); err != nil {
+					return err
+				}
+				path = ""
+				if paginate {
+					path = nextPage(resp, b)
+				}
+			}
+			return nil
+		},
+	}
+	c.Flags().StringArrayVarP(&rawFields, "raw-field", "f", nil, "Add a string field in key=value format")
+	c.Flags().StringArrayVarP(&fields, "field", "F", nil, "Add a typed field in key=value format (@file reads a file)")
+	c.Flags().StringArrayVarP(&headers, "header", "H", nil, "Add a request header in key:value format")
+	c.Flags().StringVar(&input, "input", "", "File to use as the request body (- for stdin)")
+	c.Flags().BoolVar(&paginate, "paginate", false, "Fetch all pages of results")
+	c.Flags().BoolVarP(&include, "include", "i", false, "Include HTTP status and response headers in the output")
+	c.Flags().StringVarP(&jqExpr, "jq", "q", "", "Filter JSON output with a jq-style expression")
+	return c
+}
+
+func parseFields(rawFields, fields []string) (map[string]any, error) {
+	params := map[string]any{}
+	for _, f := range rawFields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q: expected key=value", f)
+		}
+		params[k] = v
+	}
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q: expected key=value", f)
+		}
+		val, err := typedValue(v)
+		if err != nil {
+			return nil, err
+		}
+		params[k] = val
+	}
+	return params, nil
+}
+
+func typedValue(v string) (any, error) {
+	switch v {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n, nil
+	}
+	if strings.HasPrefix(v, "@") {
+		b, err := readFileOrStdin(v[1:])
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return v, nil
+}
+
+func readFileOrStdin(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func withQuery(path string, params map[string]any) string {
+	if len(params) == 0 {
+		return path
+	}
+	q := url.Values{}
+	for k, v := range params {
+		if v == nil {
+			q.Set(k, "")
+			continue
+		}
+		q.Set(k, fmt.Sprint(v))
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + q.Encode()
+}
+
+func printHeaders(resp *http.Response) {
+	fmt.Printf("%s %s\n", resp.Proto, resp.Status)
+	names := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range resp.Header[k] {
+			fmt.Printf("%s: %s\n", k, v)
+		}
+	}
+	fmt.Println()
+}
+
+func printBody(b []byte, query *jq.Query) error {
+	if query == nil {
+		os.Stdout.Write(b)
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			fmt.Println()
+		}
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return errors.New("--jq: response is not JSON")
+	}
+	results, err := query.Run(v)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if s, ok := r.(string); ok {
+			fmt.Println(s)
+			continue
+		}
+		out, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	}
+	return nil
+}
+
+var linkNextRE = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// nextPage finds the next page of a listing, from a Link header or from the
+// next_cursor/next_page fields of a JSON envelope.
+func nextPage(resp *http.Response, body []byte) string {
+	if m := linkNextRE.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		next, err := resp.Request.URL.Parse(m[1])
+		if err != nil {
+			return ""
+		}
+		return next.String()
+	}
+	var env struct {
+		NextCursor string `json:"next_cursor"`
+		NextPage   int    `json:"next_page"`
+	}
+	if json.Unmarshal(body, &env) != nil {
+		return ""
+	}
+	u := *resp.Request.URL
+	q := u.Query()
+	switch {
+	case env.NextCursor != "":
+		q.Set("cursor", env.NextCursor)
+	case env.NextPage > 0:
+		q.Set("page", strconv.Itoa(env.NextPage))
+	default:
+		return ""
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
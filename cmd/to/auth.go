@@ -4,8 +4,11 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/textonlyio/textonly-cli/internal/auth"
+	"github.com/textonlyio/textonly-cli/internal/config"
+	"github.com/textonlyio/textonly-cli/pkg/ui"
 )
 
 func newAuthCommand() *cobra.Command {
@@ -13,19 +16,42 @@ func newAuthCommand() *cobra.Command {
 	cmd.AddCommand(newLoginCommand())
 	cmd.AddCommand(newLogoutCommand())
 	cmd.AddCommand(newWhoAmICommand())
+	cmd.AddCommand(newAuthStatusCommand())
+	cmd.AddCommand(newAuthListCommand())
+	cmd.AddCommand(newAuthSwitchCommand())
+	cmd.AddCommand(newAuthTokenCommand())
+	cmd.AddCommand(newAuthHelperCommand())
 	return cmd
 }
 
 func newLoginCommand() *cobra.Command {
-	var noOpen bool
+	var noOpen, withToken, codeOnly, web bool
+	var loginTimeout string
 	c := &cobra.Command{
 		Use:   "login",
 		Short: "Log in via magic link",
+		Example: `  to login
+  echo "$TEXTONLY_TOKEN" | to login --with-token`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return auth.Login(noOpen)
+			if withToken {
+				return auth.LoginWithToken(cmd.Context(), cmd.InOrStdin())
+			}
+			if cmd.Flags().Changed("login-timeout") {
+				viper.Set("login_timeout", loginTimeout)
+				if err := config.ValidateDurations(); err != nil {
+					return err
+				}
+			}
+			return auth.Login(cmd.Context(), auth.LoginOptions{NoOpen: noOpen, CodeOnly: codeOnly, Web: web})
 		},
 	}
 	c.Flags().BoolVar(&noOpen, "no-open", false, "Do not open the browser automatically")
+	c.Flags().BoolVar(&codeOnly, "code-only", false, "Print the user code as JSON instead of instructions and do not open a browser")
+	c.Flags().BoolVar(&withToken, "with-token", false, "Read a personal access token from stdin instead of opening a browser")
+	c.Flags().BoolVar(&web, "web", false, "Approve in the browser and return automatically, without a code (falls back to a code when no browser is available)")
+	c.MarkFlagsMutuallyExclusive("web", "code-only", "with-token")
+	c.MarkFlagsMutuallyExclusive("web", "no-open")
+	c.Flags().StringVar(&loginTimeout, "login-timeout", "", "Give up waiting for approval after this long, e.g. 2m (TO_LOGIN_TIMEOUT)")
 	return c
 }
 
@@ -34,7 +60,7 @@ func newLogoutCommand() *cobra.Command {
 		Use:   "logout",
 		Short: "Log out and revoke token",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := auth.Logout(); err != nil {
+			if err := auth.Logout(cmd.Context()); err != nil {
 				return err
 			}
 			fmt.Println("logged out")
@@ -49,9 +75,112 @@ func newWhoAmICommand() *cobra.Command {
 		Use:   "whoami",
 		Short: "Show current authenticated user",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return auth.WhoAmI(asJSON)
+			return auth.WhoAmI(cmd.Context(), asJSON)
+		},
+	}
+	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	return c
+}
+
+func newAuthStatusCommand() *cobra.Command {
+	var asJSON, showToken bool
+	c := &cobra.Command{
+		Use:   "status",
+		Short: "Show where the active credential comes from and whether it is valid",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return auth.ShowStatus(cmd.Context(), asJSON, showToken)
 		},
 	}
 	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	c.Flags().BoolVar(&showToken, "show-token", false, "Include the access token in the output")
 	return c
 }
+
+func newAuthListCommand() *cobra.Command {
+	var asJSON bool
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles and their login state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			type profile struct {
+				Name     string `json:"name"`
+				API      string `json:"api"`
+				Active   bool   `json:"active"`
+				LoggedIn bool   `json:"logged_in"`
+			}
+			var out []profile
+			for _, name := range config.Profiles() {
+				api := config.ProfileAPI(name)
+				if api == "" {
+					api = config.DefaultAPIBaseURL()
+				}
+				_, err := auth.LoadTokenFor(name, api)
+				out = append(out, profile{Name: name, API: api, Active: name == config.Profile(), LoggedIn: err == nil})
+			}
+			if asJSON {
+				ui.PrintJSON(out)
+				return nil
+			}
+			for _, p := range out {
+				mark, state := " ", "logged out"
+				if p.Active {
+					mark = "*"
+				}
+				if p.LoggedIn {
+					state = "logged in"
+				}
+				fmt.Printf("%s %s\t%s\t%s\n", mark, p.Name, p.API, state)
+			}
+			return nil
+		},
+	}
+	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	return c
+}
+
+func newAuthSwitchCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "switch <profile>",
+		Short: "Make a profile the default for future commands",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := config.ValidateProfileName(name); err != nil {
+				return err
+			}
+			known := false
+			for _, p := range config.Profiles() {
+				known = known || p == name
+			}
+			if !known {
+				return fmt.Errorf("unknown profile %q; create it with `to auth login --profile %s`", name, name)
+			}
+			if err := config.UseProfile(name); err != nil {
+				return err
+			}
+			fmt.Printf("switched to profile %s\n", name)
+			return nil
+		},
+	}
+}
+
+func newAuthHelperCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "helper <get|store|erase>",
+		Short: "Credential helper speaking the git-credential protocol on stdin/stdout",
+		Long: `Let other tools reuse the CLI login through git's credential-helper
+protocol. The request is key=value lines on stdin ending with a blank line;
+"get" answers with protocol, host, username and password (the token):
+
+  printf 'protocol=https\nhost=textonly.io\n\n' | to auth helper get
+
+Requests for other hosts and unknown operations are ignored.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"get", "store", "erase"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return auth.Helper(cmd.Context(), args[0], cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+)
+
+func newCacheCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "cache", Short: "Manage the HTTP response cache"}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Delete all cached responses",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := api.ClearCache(); err != nil {
+				return err
+			}
+			fmt.Println("cache cleared")
+			return nil
+		},
+	})
+
+	return cmd
+}
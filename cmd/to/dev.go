@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/textonlyio/textonly-cli/internal/fakeapi"
+)
+
+func newDevCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "dev", Short: "Developer tools"}
+	cmd.AddCommand(newFakeServerCommand())
+	return cmd
+}
+
+func newFakeServerCommand() *cobra.Command {
+	var opts fakeapi.Options
+	var addr string
+	c := &cobra.Command{
+		Use:   "fake-server",
+		Short: "Run an in-memory fake TextOnly API for local development",
+		Long: `Run a fake TextOnly API that implements every endpoint the CLI uses.
+
+Point the CLI at it with --api (or TO_API):
+
+  to dev fake-server --token dev &
+  TO_API=http://127.0.0.1:8787 TO_TOKEN=dev to notes list`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			srv, err := fakeapi.New(opts)
+			if err != nil {
+				return err
+			}
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "fake API listening on http://%s\n", ln.Addr())
+			hs := &http.Server{Handler: srv}
+			go func() {
+				<-cmd.Context().Done()
+				_ = hs.Shutdown(context.Background())
+			}()
+			if err := hs.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+	c.Flags().StringVar(&addr, "addr", "127.0.0.1:8787", "Address to listen on")
+	c.Flags().StringVar(&opts.DataDir, "data", "", "Persist state in this directory (default: in memory)")
+	c.Flags().StringVar(&opts.Token, "token", "", "Accept this token without logging in")
+	c.Flags().StringVar(&opts.Email, "email", "", "Email of the fake user (default dev@example.com)")
+	c.Flags().IntVar(&opts.RateLimit, "rate-limit", 0, "Allow this many requests per minute and send X-RateLimit headers (0: unlimited)")
+	c.Flags().DurationVar(&opts.TokenTTL, "token-ttl", 0, "Expire access tokens issued by login after this long (0: never)")
+	c.Flags().BoolVar(&opts.ManualApprove, "manual-approve", false, "Require visiting /activate before device logins complete")
+	return c
+}
@@ -7,19 +7,36 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/textonlyio/textonly-cli/internal/api"
 	"github.com/textonlyio/textonly-cli/internal/auth"
 	"github.com/textonlyio/textonly-cli/internal/config"
 )
 
+// doctorTimeout keeps the health check snappy unless --timeout is given.
+const doctorTimeout = 5 * time.Second
+
 func newDoctorCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "doctor",
 		Short: "Run connectivity and auth checks",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			base := config.APIBaseURL()
+			fmt.Println("profile:", config.Profile())
 			fmt.Println("API:", base)
-			client := &http.Client{Timeout: 5 * time.Second}
-			resp, err := client.Get(base + "/me")
+			transport, err := api.NewTransport()
+			if err != nil {
+				fmt.Println("tls:", err)
+				return nil
+			}
+			client := &http.Client{Timeout: config.TimeoutOr(doctorTimeout), Transport: transport}
+			req, err := http.NewRequestWithContext(cmd.Context(), http.MethodGet, base+"/me", nil)
+			if err != nil {
+				return err
+			}
+			resp, err := client.Do(req)
+			if cmd.Context().Err() != nil {
+				return cmd.Context().Err()
+			}
 			if err != nil {
 				fmt.Println("network:", err)
 			} else {
@@ -30,10 +47,10 @@ func newDoctorCommand() *cobra.Command {
 					fmt.Println("network: unexpected status", resp.StatusCode)
 				}
 			}
-			if _, err := auth.LoadToken(); err != nil {
+			if _, src, err := auth.ActiveToken(cmd.Context()); err != nil {
 				fmt.Println("auth:", err)
 			} else {
-				fmt.Println("auth: token present")
+				fmt.Println("auth: token present from", src)
 			}
 			return nil
 		},
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/auth"
+)
+
+// Exit codes are part of the CLI contract; scripts branch on them, so never
+// renumber an existing one.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitAuth       = 3
+	exitNotFound   = 4
+	exitValidation = 5
+	exitRateLimit  = 6
+	exitNetwork    = 7
+	exitServer     = 8
+	exitTimeout    = 9
+	exitCanceled   = 130
+)
+
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, context.Canceled) {
+		return exitCanceled
+	}
+	if errors.Is(err, auth.ErrAccessDenied) {
+		return exitAuth
+	}
+	var timeoutErr *api.TimeoutError
+	if errors.As(err, &timeoutErr) || errors.Is(err, auth.ErrLoginExpired) {
+		return exitTimeout
+	}
+	var limitErr *api.RateLimitError
+	if errors.As(err, &limitErr) {
+		return exitRateLimit
+	}
+	var netErr *api.NetworkError
+	if errors.As(err, &netErr) {
+		return exitNetwork
+	}
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) {
+		return exitError
+	}
+	switch {
+	case apiErr.IsAuth():
+		return exitAuth
+	case apiErr.IsNotFound():
+		return exitNotFound
+	case apiErr.IsValidation():
+		return exitValidation
+	case apiErr.IsRateLimit():
+		return exitRateLimit
+	case apiErr.IsServer():
+		return exitServer
+	}
+	return exitError
+}
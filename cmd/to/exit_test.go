@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/auth"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, exitOK},
+		{"plain", errors.New("boom"), exitError},
+		{"canceled", context.Canceled, exitCanceled},
+		{"wrapped canceled", fmt.Errorf("listing: %w", context.Canceled), exitCanceled},
+		{"access denied", auth.ErrAccessDenied, exitAuth},
+		{"login expired", auth.ErrLoginExpired, exitTimeout},
+		{"timeout", &api.TimeoutError{Op: "GET /notes", After: time.Second}, exitTimeout},
+		{"network", &api.NetworkError{Err: errors.New("connection refused")}, exitNetwork},
+		{"held back by rate limit", &api.RateLimitError{Reset: time.Now().Add(time.Hour)}, exitRateLimit},
+		{"401", &api.APIError{StatusCode: 401}, exitAuth},
+		{"403", &api.APIError{StatusCode: 403}, exitAuth},
+		{"404", &api.APIError{StatusCode: 404}, exitNotFound},
+		{"400", &api.APIError{StatusCode: 400}, exitValidation},
+		{"422", &api.APIError{StatusCode: 422}, exitValidation},
+		{"429", &api.APIError{StatusCode: 429}, exitRateLimit},
+		{"500", &api.APIError{StatusCode: 500}, exitServer},
+		{"wrapped 503", fmt.Errorf("creating note: %w", &api.APIError{StatusCode: 503}), exitServer},
+		{"409", &api.APIError{StatusCode: 409}, exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,10 +24,26 @@ var (
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// Restore default signal handling after the first signal so a second
+		// Ctrl-C kills the process even if a command ignores cancellation.
+		<-ctx.Done()
+		stop()
+	}()
 	rootCmd := newRootCommand()
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if herr := api.WriteDebugFile(); herr != nil {
+		fmt.Fprintln(os.Stderr, "debug file:", herr)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintln(os.Stderr, "interrupted")
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -31,14 +51,49 @@ func newRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "to",
 		Short: "TextOnly CLI",
+		// main prints the error itself; usage is noise once flags parsed.
+		SilenceErrors: true,
+		SilenceUsage:  true,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			api.SetUserAgent(fmt.Sprintf("to/%s (%s/%s)", version, runtime.GOOS, runtime.GOARCH))
-			return config.Init()
+			if err := config.Init(); err != nil {
+				return err
+			}
+			if err := config.ValidateDurations(); err != nil {
+				return err
+			}
+			return config.ValidateProfileName(config.Profile())
 		},
 	}
 
 	cmd.PersistentFlags().String("api", "", "Override API base URL (TO_API)")
-	_ = viper.BindPFlag("api", cmd.PersistentFlags().Lookup("api"))
+	_ = viper.BindPFlag("api_override", cmd.PersistentFlags().Lookup("api"))
+	cmd.PersistentFlags().String("profile", "", "Account profile to use (TO_PROFILE)")
+	_ = viper.BindPFlag("profile", cmd.PersistentFlags().Lookup("profile"))
+	cmd.PersistentFlags().String("token", "", "Access token to use instead of stored credentials (visible to other users in ps; prefer TO_TOKEN_FILE or token_command)")
+	_ = viper.BindPFlag("token_flag", cmd.PersistentFlags().Lookup("token"))
+	cmd.PersistentFlags().Int("retries", 3, "Retries for transient failures on idempotent requests (TO_RETRIES)")
+	_ = viper.BindPFlag("retries", cmd.PersistentFlags().Lookup("retries"))
+	cmd.PersistentFlags().String("timeout", "", "Overall deadline per API request, e.g. 30s or 5m; 0 disables (TO_TIMEOUT, default 20s)")
+	_ = viper.BindPFlag("timeout", cmd.PersistentFlags().Lookup("timeout"))
+	cmd.PersistentFlags().String("connect-timeout", "", "Deadline for connecting and the TLS handshake (TO_CONNECT_TIMEOUT, default 10s)")
+	_ = viper.BindPFlag("connect_timeout", cmd.PersistentFlags().Lookup("connect-timeout"))
+	cmd.PersistentFlags().Bool("debug", false, "Log HTTP requests and responses to stderr (TO_DEBUG)")
+	_ = viper.BindPFlag("debug", cmd.PersistentFlags().Lookup("debug"))
+	cmd.PersistentFlags().String("debug-file", "", "Write a HAR capture of HTTP traffic to this file (TO_DEBUG_FILE)")
+	_ = viper.BindPFlag("debug_file", cmd.PersistentFlags().Lookup("debug-file"))
+	cmd.PersistentFlags().Bool("no-cache", false, "Bypass the on-disk HTTP response cache (TO_NO_CACHE)")
+	_ = viper.BindPFlag("no_cache", cmd.PersistentFlags().Lookup("no-cache"))
+	cmd.PersistentFlags().String("ca-file", "", "PEM bundle of extra CAs to trust (TO_CA_FILE)")
+	_ = viper.BindPFlag("ca_file", cmd.PersistentFlags().Lookup("ca-file"))
+	cmd.PersistentFlags().String("client-cert", "", "PEM client certificate for mTLS (TO_CLIENT_CERT)")
+	_ = viper.BindPFlag("client_cert", cmd.PersistentFlags().Lookup("client-cert"))
+	cmd.PersistentFlags().String("client-key", "", "PEM private key for --client-cert (TO_CLIENT_KEY)")
+	_ = viper.BindPFlag("client_key", cmd.PersistentFlags().Lookup("client-key"))
+	cmd.PersistentFlags().String("tls-min-version", "", "Minimum TLS version: 1.2 (default) or 1.3 (TO_TLS_MIN_VERSION)")
+	_ = viper.BindPFlag("tls_min_version", cmd.PersistentFlags().Lookup("tls-min-version"))
+	cmd.PersistentFlags().Bool("insecure-skip-verify", false, "Disable TLS certificate verification (unsafe) (TO_INSECURE_SKIP_VERIFY)")
+	_ = viper.BindPFlag("insecure_skip_verify", cmd.PersistentFlags().Lookup("insecure-skip-verify"))
 
 	// Top-level auth commands
 	cmd.AddCommand(newLoginCommand())
@@ -49,10 +104,14 @@ func newRootCommand() *cobra.Command {
 	cmd.AddCommand(newAuthCommand())
 	cmd.AddCommand(newNotesCommand())
 	cmd.AddCommand(newConfigCommand())
+	cmd.AddCommand(newAPICommand())
+	cmd.AddCommand(newCacheCommand())
 	cmd.AddCommand(newCompletionCommand())
 	cmd.AddCommand(newUpdateCommand())
 	cmd.AddCommand(newVersionCommand())
 	cmd.AddCommand(newDoctorCommand())
+	cmd.AddCommand(newStatusCommand())
+	cmd.AddCommand(newDevCommand())
 
 	return cmd
 }
@@ -18,7 +18,7 @@ func newNotesCommand() *cobra.Command {
 		if len(args) >= 3 && args[1] == "make" && (args[2] == "public" || args[2] == "private") {
 			id := args[0]
 			visibility := args[2]
-			if err := notes.SetVisibility(id, visibility); err != nil {
+			if err := notes.SetVisibility(cmd.Context(), id, visibility); err != nil {
 				return err
 			}
 			fmt.Printf("note %s is now %s\n", id, visibility)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/auth"
+	"github.com/textonlyio/textonly-cli/internal/config"
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+	"github.com/textonlyio/textonly-cli/pkg/ui"
+)
+
+func newStatusCommand() *cobra.Command {
+	var asJSON bool
+	c := &cobra.Command{
+		Use:   "status",
+		Short: "Show the API, signed-in user and remaining rate-limit budget",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := auth.NewClient()
+			me, err := textonly.NewClient(client).Users.Me(cmd.Context())
+			var apiErr *api.APIError
+			if err != nil && !(errors.As(err, &apiErr) && apiErr.IsAuth()) {
+				return err
+			}
+			rl, known := client.RateLimit()
+
+			if asJSON {
+				out := map[string]any{"profile": config.Profile(), "api": config.APIBaseURL(), "authenticated": me != nil}
+				if me != nil && len(me.Raw) > 0 {
+					out["user"] = me.Raw
+				} else if me != nil {
+					out["user"] = me
+				}
+				if known {
+					out["rate_limit"] = rl
+				}
+				ui.PrintJSON(out)
+				return nil
+			}
+			fmt.Println("profile:", config.Profile())
+			fmt.Println("API:", config.APIBaseURL())
+			if me != nil {
+				fmt.Println("user:", me.Email)
+			} else {
+				fmt.Println("user: not authenticated")
+			}
+			if !known {
+				fmt.Println("rate limit: not reported by server")
+				return nil
+			}
+			fmt.Printf("rate limit: %d/%d remaining", rl.Remaining, rl.Limit)
+			if !rl.Reset.IsZero() {
+				fmt.Printf(", resets in %s", time.Until(rl.Reset).Round(time.Second))
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	return c
+}
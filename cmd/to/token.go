@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/textonlyio/textonly-cli/internal/auth"
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+	"github.com/textonlyio/textonly-cli/pkg/ui"
+)
+
+func newAuthTokenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Print the active access token, or manage personal access tokens",
+		Long: `Print the access token the CLI would use, for scripts:
+
+  curl -H "Authorization: Bearer $(to auth token)" https://textonly.io/api/me
+
+The create, list and revoke subcommands manage personal access tokens.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tok, err := auth.Token(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Println(tok)
+			return nil
+		},
+	}
+	cmd.AddCommand(newTokenCreateCommand())
+	cmd.AddCommand(newTokenListCommand())
+	cmd.AddCommand(newTokenRevokeCommand())
+	return cmd
+}
+
+func newTokenCreateCommand() *cobra.Command {
+	var name, expires string
+	var scopes []string
+	var asJSON bool
+	c := &cobra.Command{
+		Use:   "create --name NAME",
+		Short: "Create a personal access token for CI and scripts (use as TO_TOKEN)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			params := textonly.CreateTokenParams{Name: name, Scopes: scopes}
+			if expires != "" {
+				d, err := parseExpiry(expires)
+				if err != nil {
+					return err
+				}
+				if d > 0 {
+					at := time.Now().Add(d).UTC().Truncate(time.Second)
+					params.ExpiresAt = &at
+				}
+			}
+			t, err := textonly.NewClient(auth.NewClient()).Tokens.Create(cmd.Context(), params)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				ui.PrintJSON(t)
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "Copy the token now; it will not be shown again.")
+			fmt.Println(t.Secret)
+			return nil
+		},
+	}
+	c.Flags().StringVar(&name, "name", "", "Name to recognize the token by")
+	c.Flags().StringSliceVar(&scopes, "scopes", nil, "Comma-separated scopes, e.g. notes:read,notes:write (default: server default)")
+	c.Flags().StringVar(&expires, "expires", "", "Lifetime such as 90d or 12h, or never (default: server default)")
+	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	_ = c.MarkFlagRequired("name")
+	return c
+}
+
+func newTokenListCommand() *cobra.Command {
+	var asJSON bool
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List personal access tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := textonly.NewClient(auth.NewClient()).Tokens.List(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				if tokens == nil {
+					tokens = []textonly.Token{}
+				}
+				ui.PrintJSON(tokens)
+				return nil
+			}
+			for _, t := range tokens {
+				expires := "never"
+				if t.ExpiresAt != nil {
+					expires = t.ExpiresAt.Format(time.RFC3339)
+				}
+				fmt.Printf("%s\t%s\t%s\texpires %s\n", t.ID, t.Name, strings.Join(t.Scopes, ","), expires)
+			}
+			return nil
+		},
+	}
+	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	return c
+}
+
+func newTokenRevokeCommand() *cobra.Command {
+	var asJSON bool
+	c := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke a personal access token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := textonly.NewClient(auth.NewClient()).Tokens.Revoke(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			if asJSON {
+				ui.PrintJSON(map[string]any{"id": args[0], "revoked": true})
+				return nil
+			}
+			fmt.Println("revoked", args[0])
+			return nil
+		},
+	}
+	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	return c
+}
+
+// parseExpiry accepts a Go duration, a number of days ("90d") or weeks
+// ("2w"), or "never", which returns 0.
+func parseExpiry(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "never" {
+		return 0, nil
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid --expires %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid --expires %q: use e.g. 90d, 12h or never", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"never", 0, false},
+		{"NEVER", 0, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{" 30m ", 30 * time.Minute, false},
+		{"0d", 0, true},
+		{"-1d", 0, true},
+		{"d", 0, true},
+		{"1.5d", 0, true},
+		{"-5h", 0, true},
+		{"0s", 0, true},
+		{"soon", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseExpiry(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseExpiry(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseExpiry(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
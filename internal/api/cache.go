@@ -0,0 +1,162 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// cacheTransport keeps GET responses that carry a validator (ETag or
+// Last-Modified) on disk and revalidates them with conditional requests, so
+// unchanged notes cost a 304 instead of a full body. Entries live under
+// <user cache dir>/textonly/http/<profile>/<host>/ and any successful write
+// to a host drops that host's entries.
+type cacheTransport struct {
+	next http.RoundTripper
+	dir  string
+}
+
+type cacheEntry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		resp, err := t.next.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions &&
+			resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			_ = os.RemoveAll(t.hostDir(req))
+		}
+		return resp, err
+	}
+	if req.Header.Get("Range") != "" || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	path := t.entryPath(req)
+	entry := readCacheEntry(path)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := entry.Header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		cached := entry.response(req)
+		// Fresh headers (rate limits, request id) win over stored ones.
+		for k, v := range resp.Header {
+			cached.Header[k] = v
+		}
+		return cached, nil
+	}
+	if resp.StatusCode != http.StatusOK || !cacheable(resp) {
+		if entry != nil {
+			_ = os.Remove(path)
+		}
+		return resp, nil
+	}
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	writeCacheEntry(path, &cacheEntry{Status: resp.StatusCode, Header: resp.Header, Body: b})
+	return resp, nil
+}
+
+func cacheable(resp *http.Response) bool {
+	if strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func (t *cacheTransport) hostDir(req *http.Request) string {
+	return filepath.Join(t.dir, sanitizeHost(req.URL.Host))
+}
+
+// entryPath keys entries by URL and credential, so switching tokens within a
+// profile never serves another identity's data.
+func (t *cacheTransport) entryPath(req *http.Request) string {
+	h := sha256.Sum256([]byte(req.URL.String() + "\x00" + req.Header.Get("Authorization")))
+	return filepath.Join(t.hostDir(req), hex.EncodeToString(h[:])+".json")
+}
+
+func sanitizeHost(host string) string {
+	return strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(host)
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        http.StatusText(e.Status),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func readCacheEntry(path string) *cacheEntry {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if json.Unmarshal(b, &e) != nil {
+		return nil
+	}
+	return &e
+}
+
+func writeCacheEntry(path string, e *cacheEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".entry.*")
+	if err != nil {
+		return
+	}
+	tmp := f.Name()
+	_, werr := f.Write(b)
+	cerr := f.Close()
+	if werr != nil || cerr != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+	}
+}
+
+// ClearCache removes every cached response for all profiles.
+func ClearCache() error {
+	return os.RemoveAll(filepath.Join(config.CacheDir(), "http"))
+}
@@ -2,21 +2,50 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/textonlyio/textonly-cli/internal/config"
 )
 
 type Client struct {
+	// err records a construction failure (e.g. an unreadable CA file) and
+	// is returned by every call.
+	err           error
 	baseURL       string
 	httpClient    *http.Client
 	tokenProvider func() (string, error)
+	maxRetries    int
+	retryBase     time.Duration
+	// timeout bounds a whole call: every attempt, the waits between them
+	// and reading the response body.
+	timeout time.Duration
+	limiter *rateLimiter
+	tokens  TokenSource
+	// connectTimeout is only used to word connect timeout errors.
+	connectTimeout   time.Duration
+	insecureHTTPAuth bool
+}
+
+// ErrNoToken is returned by a TokenSource that has no credentials; the
+// request then goes out unauthenticated and the server decides.
+var ErrNoToken = errors.New("not logged in")
+
+// TokenSource supplies a renewable bearer token. Token may renew credentials
+// that are about to expire; Refresh is called once when the server rejects
+// the current token with 401.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+	Refresh(ctx context.Context) (string, error)
 }
 
 var userAgent = "to/dev (unknown/unknown)"
@@ -24,60 +53,360 @@ var userAgent = "to/dev (unknown/unknown)"
 func SetUserAgent(ua string) { if ua != "" { userAgent = ua } }
 
 func New(tokenProvider func() (string, error)) *Client {
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.Proxy = http.ProxyFromEnvironment
+	return NewWithBaseURL(config.APIBaseURL(), tokenProvider)
+}
+
+// NewWithBaseURL is New for an explicit API base URL instead of the
+// configured one.
+func NewWithBaseURL(baseURL string, tokenProvider func() (string, error)) *Client {
+	transport, err := NewTransport()
+	if err != nil {
+		return &Client{err: err}
+	}
+	cacheDir := ""
+	if !config.NoCache() {
+		cacheDir = filepath.Join(config.CacheDir(), "http", config.Profile())
+	}
+	return NewWithOptions(Options{
+		BaseURL:          baseURL,
+		TokenProvider:    tokenProvider,
+		HTTPClient:       &http.Client{Transport: withDebug(transport)},
+		Retries:          config.Retries(),
+		Timeout:          config.Timeout(),
+		ConnectTimeout:   config.ConnectTimeout(),
+		CacheDir:         cacheDir,
+		InsecureHTTPAuth: config.InsecureHTTPAuth(),
+	})
+}
+
+// Options configures NewWithOptions. Unlike New, nothing is read from the
+// CLI configuration: every setting is explicit and zero means off.
+type Options struct {
+	BaseURL       string
+	TokenProvider func() (string, error)
+	// HTTPClient sends the requests; nil uses http.DefaultTransport. Its
+	// Timeout should be left at zero in favour of Timeout.
+	HTTPClient *http.Client
+	// Retries is how many times idempotent requests are retried.
+	Retries int
+	// Timeout bounds each call, retries included.
+	Timeout time.Duration
+	// ConnectTimeout is the dial deadline of HTTPClient's transport, used
+	// only to word errors.
+	ConnectTimeout time.Duration
+	// CacheDir, if set, keeps GET responses there for revalidation.
+	CacheDir string
+	// InsecureHTTPAuth allows sending the token over plain http to hosts
+	// other than localhost.
+	InsecureHTTPAuth bool
+}
+
+// NewWithOptions returns a client configured only by o.
+func NewWithOptions(o Options) *Client {
+	baseURL := strings.TrimRight(o.BaseURL, "/")
+	host := baseURL
+	if u, err := url.Parse(baseURL); err == nil {
+		host = u.Host
+	}
+	hc := &http.Client{}
+	if o.HTTPClient != nil {
+		copied := *o.HTTPClient
+		hc = &copied
+	}
+	if o.CacheDir != "" {
+		next := hc.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc.Transport = &cacheTransport{next: next, dir: o.CacheDir}
+	}
 	return &Client{
-		baseURL:       config.APIBaseURL(),
-		httpClient:    &http.Client{Timeout: 20 * time.Second, Transport: transport},
-		tokenProvider: tokenProvider,
+		baseURL:          baseURL,
+		httpClient:       hc,
+		tokenProvider:    o.TokenProvider,
+		maxRetries:       o.Retries,
+		retryBase:        500 * time.Millisecond,
+		timeout:          o.Timeout,
+		limiter:          limiterFor(host),
+		connectTimeout:   o.ConnectTimeout,
+		insecureHTTPAuth: o.InsecureHTTPAuth,
 	}
 }
 
+// SetTimeout overrides the overall deadline of each call, retries
+// included; 0 disables it. Commands with unusual needs (uploads, health
+// checks) use this with config.TimeoutOr so an explicit --timeout still
+// wins.
+func (c *Client) SetTimeout(d time.Duration) { c.timeout = d }
+
+// SetTokenSource makes the client take tokens from ts instead of its token
+// provider, refreshing and retrying once on 401.
+func (c *Client) SetTokenSource(ts TokenSource) { c.tokens = ts }
+
 func (c *Client) Do(method, path string, body any, requireAuth bool, out any) error {
-	var bodyReader io.Reader
-	if body != nil {
+	return c.DoContext(context.Background(), method, path, body, requireAuth, out)
+}
+
+// DoContext is Do bound to ctx; cancelling ctx aborts the in-flight request
+// and any pending retry. body is sent as JSON, except that url.Values is
+// form-encoded and a *StreamBody is streamed.
+func (c *Client) DoContext(ctx context.Context, method, path string, body any, requireAuth bool, out any) error {
+	var reqBody *requestBody
+	var header http.Header
+	if sb, ok := body.(*StreamBody); ok {
+		rb, err := sb.requestBody()
+		if err != nil {
+			return err
+		}
+		reqBody, header = rb, sb.header()
+	} else if form, ok := body.(url.Values); ok {
+		reqBody = bytesBody([]byte(form.Encode()))
+		header = http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	} else if body != nil {
 		b, err := json.Marshal(body)
 		if err != nil {
 			return err
 		}
-		bodyReader = bytes.NewReader(b)
+		reqBody = bytesBody(b)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+	resp, err := c.send(ctx, method, path, reqBody, header, requireAuth)
 	if err != nil {
 		return err
 	}
+	err = decodeResponse(resp, out)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return &TimeoutError{Op: method + " " + path, After: c.timeout}
+	}
+	return err
+}
+
+// Raw performs an authenticated call with a caller-encoded body and extra
+// headers, and returns the response unread whatever its status. It backs
+// `to api`; everything else should use DoContext.
+func (c *Client) Raw(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	return c.send(ctx, method, path, bytesBody(body), header, true)
+}
+
+// requestBody yields a fresh reader for each attempt. Bodies that cannot be
+// rewound are sent once and never retried. close, if set, is called before
+// send returns and releases whatever the last reader still holds.
+type requestBody struct {
+	open       func() (io.Reader, error)
+	close      func()
+	replayable bool
+}
+
+func bytesBody(b []byte) *requestBody {
+	if b == nil {
+		return nil
+	}
+	return &requestBody{
+		open:       func() (io.Reader, error) { return bytes.NewReader(b), nil },
+		replayable: true,
+	}
+}
+
+// send runs the request through the retry loop. The client timeout is one
+// deadline for the whole loop, backoff and rate-limit waits included, so
+// retries never stretch a call past it; a retry whose wait would not fit
+// returns the last response instead. The caller owns the returned response
+// body.
+func (c *Client) send(ctx context.Context, method, path string, body *requestBody, header http.Header, requireAuth bool) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	// Resolve the token before the deadline starts: a token source may run
+	// an external command that waits on a password manager prompt, and
+	// bounds that itself. Later calls within the request hit its cache.
+	if requireAuth && c.tokens != nil {
+		if _, err := c.tokens.Token(ctx); err != nil && !errors.Is(err, ErrNoToken) {
+			return nil, err
+		}
+	}
+	parent := ctx
+	cancel := context.CancelFunc(func() {})
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+	// stop ends the call with err, reporting our own deadline running out
+	// as a TimeoutError rather than the caller cancelling.
+	stop := func(err error) (*http.Response, error) {
+		cancel()
+		if parent.Err() == nil && ctx.Err() != nil {
+			return nil, &TimeoutError{Op: method + " " + path, After: c.timeout}
+		}
+		return nil, err
+	}
+	if body != nil && body.close != nil {
+		defer body.close()
+	}
+	idemKey := c.idempotencyKey(ctx, method, path)
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return stop(err)
+		}
+		var r io.Reader
+		if body != nil {
+			b, err := body.open()
+			if err != nil {
+				return stop(err)
+			}
+			r = b
+		}
+		req, err := c.newRequest(ctx, method, path, r, requireAuth)
+		if err != nil {
+			return stop(err)
+		}
+		if idemKey != "" {
+			req.Header.Set("Idempotency-Key", idemKey)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := c.httpClient.Do(req)
+		if resp != nil {
+			c.limiter.update(resp.Header)
+		}
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized && !refreshed && c.canRefresh(req, body) {
+			refreshed = true
+			if _, rerr := c.tokens.Refresh(ctx); rerr == nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				attempt--
+				continue
+			}
+		}
+		wait, retry := c.shouldRetry(ctx, req, resp, err, attempt)
+		if retry && body != nil && !body.replayable {
+			retry = false
+		}
+		if retry && wait <= 0 {
+			wait = backoff(c.retryBase, attempt)
+		}
+		if retry && fitsDeadline(ctx, wait) {
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+			if err := sleepContext(ctx, wait); err != nil {
+				return stop(err)
+			}
+			continue
+		}
+		if err != nil {
+			cancel()
+			if parent.Err() != nil {
+				return nil, parent.Err()
+			}
+			if te := c.timeoutError(req, ctx, err); te != nil {
+				return nil, te
+			}
+			return nil, &NetworkError{Err: err}
+		}
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+}
+
+// canRefresh reports whether a 401 to req may be answered by refreshing the
+// token and sending it again: the token must have come from the token source
+// and the body must be replayable.
+func (c *Client) canRefresh(req *http.Request, body *requestBody) bool {
+	return c.tokens != nil && req.Header.Get("Authorization") != "" && (body == nil || body.replayable)
+}
+
+// cancelOnClose releases the call's deadline once its body is consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader, requireAuth bool) (*http.Request, error) {
+	u, err := c.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Accept", "application/json")
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	}
 	req.Header.Set("User-Agent", userAgent)
 	if requireAuth {
-		if tokenEnv := os.Getenv("TO_TOKEN"); tokenEnv != "" {
-			req.Header.Set("Authorization", "Bearer "+tokenEnv)
+		var tok string
+		if c.tokens != nil {
+			var err error
+			if tok, err = c.tokens.Token(ctx); err != nil && !errors.Is(err, ErrNoToken) {
+				return nil, err
+			}
 		} else if c.tokenProvider != nil {
-			if tok, err := c.tokenProvider(); err == nil && tok != "" {
-				req.Header.Set("Authorization", "Bearer "+tok)
+			tok, _ = c.tokenProvider()
+		}
+		if tok != "" {
+			if err := c.checkAuthScheme(req.URL); err != nil {
+				return nil, err
 			}
+			req.Header.Set("Authorization", "Bearer "+tok)
 		}
 	}
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
+	return req, nil
+}
+
+// checkAuthScheme refuses to put a bearer token on a plain-http request
+// unless it stays on this machine or the user opted in.
+func (c *Client) checkAuthScheme(u *url.URL) error {
+	if u.Scheme != "http" || isLoopback(u.Hostname()) || c.insecureHTTPAuth {
+		return nil
+	}
+	return fmt.Errorf("refusing to send credentials over plain http to %s; use https or set insecure_http_auth (TO_INSECURE_HTTP_AUTH=1)", u.Host)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
 	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// resolve turns an API path into a URL. Absolute URLs, such as pagination
+// links, are accepted only when they point below the API base URL.
+func (c *Client) resolve(path string) (string, error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		if path != c.baseURL && !strings.HasPrefix(path, c.baseURL+"/") && !strings.HasPrefix(path, c.baseURL+"?") {
+			return "", fmt.Errorf("refusing to request %s: outside API base URL %s", path, c.baseURL)
+		}
+		return path, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return c.baseURL + path, nil
+}
+
+func decodeResponse(resp *http.Response, out any) error {
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("api error: %d %s", resp.StatusCode, string(b))
+		return parseAPIError(resp)
 	}
 	if out != nil {
-		return json.NewDecoder(resp.Body).Decode(out)
+		err := json.NewDecoder(resp.Body).Decode(out)
+		if err == io.EOF {
+			// 204 No Content and empty 200s leave out untouched.
+			return nil
+		}
+		return err
 	}
 	return nil
 }
-
-func sleepWithJitter(base time.Duration, attempt int) {
-	delay := base << attempt
-	jitter := time.Duration(rand.Int63n(int64(delay/2))) - delay/4
-	time.Sleep(delay + jitter)
-}
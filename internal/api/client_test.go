@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowTokens takes delay to produce its first token, like a token_command
+// waiting on a password manager, and then serves it from memory.
+type slowTokens struct {
+	delay time.Duration
+	tok   string
+}
+
+func (s *slowTokens) Token(ctx context.Context) (string, error) {
+	if s.tok != "" {
+		return s.tok, nil
+	}
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	s.tok = "t"
+	return s.tok, nil
+}
+
+func (s *slowTokens) Refresh(ctx context.Context) (string, error) { return s.Token(ctx) }
+
+// TestTokenOutsideTimeout checks that a slow token source doesn't eat into
+// the request timeout.
+func TestTokenOutsideTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		baseURL:    ts.URL,
+		httpClient: &http.Client{},
+		timeout:    100 * time.Millisecond,
+		limiter:    &rateLimiter{},
+		tokens:     &slowTokens{delay: 300 * time.Millisecond},
+	}
+	if err := c.DoContext(context.Background(), "GET", "/me", nil, true, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,377 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// maxDebugBody bounds how much of each body is logged to stderr. HAR
+// entries keep the full (redacted) body.
+const maxDebugBody = 4 << 10
+
+const redacted = "[REDACTED]"
+
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// sensitiveHeaderName matches custom credential headers such as X-Api-Key
+// or X-Auth-Token, which `to api -H` can send.
+var sensitiveHeaderName = regexp.MustCompile(`(?i)token|api-?key|secret|password|passphrase|credential`)
+
+// sensitiveKeys names the JSON fields and query/form parameters that carry
+// credentials, in any case: access_token, accessToken, api_key, apiKey...
+const sensitiveKeys = `[a-z_]*token|api[-_]?key|device_code|code|code_verifier|password|secret|client_secret|passphrase`
+
+var tokenPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)("(?:` + sensitiveKeys + `)"\s*:\s*)"[^"]*"`), `$1"` + redacted + `"`},
+	{regexp.MustCompile(`(?i)((?:^|[?&])(?:` + sensitiveKeys + `)=)[^&\s]*`), `${1}` + redacted},
+	// Bearer credentials, JWT-shaped strings and personal access tokens
+	// wherever they appear.
+	{regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`), `${1}` + redacted},
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`), redacted},
+	{regexp.MustCompile(`\bpat_[A-Za-z0-9_-]{8,}`), redacted},
+}
+
+// Redact removes credentials from text destined for logs.
+func Redact(s string) string {
+	for _, p := range tokenPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+func redactHeaders(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(k)] || sensitiveHeaderName.MatchString(k) {
+			out[k] = []string{redacted}
+			continue
+		}
+		vs := make([]string, len(v))
+		for i := range v {
+			vs[i] = Redact(v[i])
+		}
+		out[k] = vs
+	}
+	return out
+}
+
+func redactURL(u string) string {
+	if i := strings.IndexByte(u, '?'); i >= 0 {
+		return u[:i] + Redact(u[i:])
+	}
+	return u
+}
+
+// debugTransport logs every exchange to stderr and/or records it into the
+// HAR file, depending on which of --debug and --debug-file are set.
+type debugTransport struct {
+	next http.RoundTripper
+	log  io.Writer
+	har  *harRecorder
+}
+
+// withDebug wraps rt when debugging is enabled in config.
+func withDebug(rt http.RoundTripper) http.RoundTripper {
+	t := &debugTransport{next: rt}
+	if config.Debug() {
+		t.log = os.Stderr
+	}
+	if path := config.DebugFile(); path != "" {
+		t.har = harFor(path)
+	}
+	if t.log == nil && t.har == nil {
+		return rt
+	}
+	return t
+}
+
+type traceTimes struct {
+	start, dnsStart, dnsDone, connStart, connDone, tlsStart, tlsDone, wrote, firstByte time.Time
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var tt traceTimes
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { tt.dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { tt.dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { tt.connStart = time.Now() },
+		ConnectDone:          func(string, string, error) { tt.connDone = time.Now() },
+		TLSHandshakeStart:    func() { tt.tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { tt.tlsDone = time.Now() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { tt.wrote = time.Now() },
+		GotFirstResponseByte: func() { tt.firstByte = time.Now() },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	reqBody := "<streamed body>"
+	if req.Body == nil || req.Body == http.NoBody {
+		reqBody = ""
+	} else if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(rc)
+			_ = rc.Close()
+			reqBody = Redact(string(b))
+		}
+	}
+	if t.log != nil {
+		fmt.Fprintf(t.log, "> %s %s\n", req.Method, redactURL(req.URL.String()))
+		writeHeaders(t.log, "> ", redactHeaders(req.Header))
+		writeBody(t.log, "> ", reqBody)
+	}
+
+	tt.start = time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		if t.log != nil {
+			fmt.Fprintf(t.log, "< error after %s: %v\n\n", time.Since(tt.start).Round(time.Millisecond), err)
+		}
+		return nil, err
+	}
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	end := time.Now()
+	respBody := Redact(string(b))
+
+	if t.log != nil {
+		fmt.Fprintf(t.log, "< %s %s (%s)\n", resp.Proto, resp.Status, tt.summary(end))
+		writeHeaders(t.log, "< ", redactHeaders(resp.Header))
+		writeBody(t.log, "< ", respBody)
+		fmt.Fprintln(t.log)
+	}
+	if t.har != nil {
+		t.har.add(req, reqBody, resp, respBody, tt, end)
+	}
+	return resp, err
+}
+
+func (tt traceTimes) summary(end time.Time) string {
+	var parts []string
+	add := func(name string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, to.Sub(from).Round(time.Microsecond)))
+		}
+	}
+	add("dns", tt.dnsStart, tt.dnsDone)
+	add("connect", tt.connStart, tt.connDone)
+	add("tls", tt.tlsStart, tt.tlsDone)
+	add("ttfb", tt.start, tt.firstByte)
+	add("total", tt.start, end)
+	return strings.Join(parts, " ")
+}
+
+func writeHeaders(w io.Writer, prefix string, h http.Header) {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+}
+
+func writeBody(w io.Writer, prefix, body string) {
+	if body == "" {
+		return
+	}
+	if len(body) > maxDebugBody {
+		body = body[:maxDebugBody] + fmt.Sprintf("... (%d bytes truncated)", len(body)-maxDebugBody)
+	}
+	fmt.Fprintf(w, "%s\n", prefix)
+	for _, line := range strings.Split(body, "\n") {
+		fmt.Fprintf(w, "%s%s\n", prefix, line)
+	}
+}
+
+// HAR 1.2 (http://www.softwareishard.com/blog/har-12-spec/), trimmed to the
+// fields support tooling actually reads.
+type harNV struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Headers     []harNV      `json:"headers"`
+	QueryString []harNV      `json:"queryString"`
+	Cookies     []harNV      `json:"cookies"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+	PostData    *harPostData `json:"postData,omitempty"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int        `json:"status"`
+	StatusText  string     `json:"statusText"`
+	HTTPVersion string     `json:"httpVersion"`
+	Headers     []harNV    `json:"headers"`
+	Cookies     []harNV    `json:"cookies"`
+	Content     harContent `json:"content"`
+	RedirectURL string     `json:"redirectURL"`
+	HeadersSize int        `json:"headersSize"`
+	BodySize    int        `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harRecorder struct {
+	mu      sync.Mutex
+	path    string
+	entries []harEntry
+}
+
+var (
+	harMu  sync.Mutex
+	harLog *harRecorder
+)
+
+func harFor(path string) *harRecorder {
+	harMu.Lock()
+	defer harMu.Unlock()
+	if harLog == nil || harLog.path != path {
+		harLog = &harRecorder{path: path}
+	}
+	return harLog
+}
+
+func ms(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+func harHeaders(h http.Header) []harNV {
+	out := []harNV{}
+	for k, vs := range redactHeaders(h) {
+		for _, v := range vs {
+			out = append(out, harNV{Name: k, Value: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (r *harRecorder) add(req *http.Request, reqBody string, resp *http.Response, respBody string, tt traceTimes, end time.Time) {
+	e := harEntry{
+		StartedDateTime: tt.start.Format(time.RFC3339Nano),
+		Time:            ms(tt.start, end),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         redactURL(req.URL.String()),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNV{},
+			Cookies:     []harNV{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Headers:     harHeaders(resp.Header),
+			Cookies:     []harNV{},
+			Content:     harContent{Size: len(respBody), MimeType: resp.Header.Get("Content-Type"), Text: respBody},
+			HeadersSize: -1,
+			BodySize:    len(respBody),
+		},
+		Timings: harTimings{
+			DNS:     ms(tt.dnsStart, tt.dnsDone),
+			Connect: ms(tt.connStart, tt.connDone),
+			SSL:     ms(tt.tlsStart, tt.tlsDone),
+			Send:    0,
+			Wait:    ms(tt.wrote, tt.firstByte),
+			Receive: ms(tt.firstByte, end),
+		},
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			e.Request.QueryString = append(e.Request.QueryString, harNV{Name: k, Value: Redact(k + "=" + v)[len(k)+1:]})
+		}
+	}
+	if reqBody != "" {
+		e.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: reqBody}
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, e)
+	r.mu.Unlock()
+}
+
+// WriteDebugFile flushes the requests recorded for --debug-file as a HAR
+// archive. It is a no-op when no debug file was requested.
+func WriteDebugFile() error {
+	harMu.Lock()
+	r := harLog
+	harMu.Unlock()
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc := map[string]any{
+		"log": map[string]any{
+			"version": "1.2",
+			"creator": map[string]string{"name": "to", "version": userAgent},
+			"entries": append([]harEntry{}, r.entries...),
+		},
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, b, 0o600)
+}
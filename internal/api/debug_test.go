@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`{"access_token":"abc","title":"x"}`, `{"access_token":"[REDACTED]","title":"x"}`},
+		{`{"refresh_token": "r1"}`, `{"refresh_token": "[REDACTED]"}`},
+		{`{"device_code":"d","user_code":"U1"}`, `{"device_code":"[REDACTED]","user_code":"U1"}`},
+		{`{"code":"c0de","code_verifier":"v","grant_type":"authorization_code"}`, `{"code":"[REDACTED]","code_verifier":"[REDACTED]","grant_type":"authorization_code"}`},
+		{`{"password":"p","secret":"s","client_secret":"cs","passphrase":"pp"}`, `{"password":"[REDACTED]","secret":"[REDACTED]","client_secret":"[REDACTED]","passphrase":"[REDACTED]"}`},
+		{`{"error":"invalid_grant","message":"bad code"}`, `{"error":"invalid_grant","message":"bad code"}`},
+		{`?code=abc&state=xyz`, `?code=[REDACTED]&state=xyz`},
+		{`?state=xyz&access_token=t&x=1`, `?state=xyz&access_token=[REDACTED]&x=1`},
+		{`?zipcode=12345`, `?zipcode=12345`},
+		{`Authorization: Bearer abc.def-ghi`, `Authorization: Bearer [REDACTED]`},
+		{`token eyJhbGciOi.eyJzdWIiOi.c2lnbmF0dXJl here`, `token [REDACTED] here`},
+		{`plain text`, `plain text`},
+		{`{"accessToken":"a","Token":"b","api_key":"c","apiKey":"d","API-KEY":"e"}`, `{"accessToken":"[REDACTED]","Token":"[REDACTED]","api_key":"[REDACTED]","apiKey":"[REDACTED]","API-KEY":"[REDACTED]"}`},
+		{`{"Password":"p","Code":"c"}`, `{"Password":"[REDACTED]","Code":"[REDACTED]"}`},
+		{`?apiKey=k1&api_key=k2&accessToken=t`, `?apiKey=[REDACTED]&api_key=[REDACTED]&accessToken=[REDACTED]`},
+		{`grant_type=authorization_code&code=c&code_verifier=v`, `grant_type=authorization_code&code=[REDACTED]&code_verifier=[REDACTED]`},
+		{`{"note":"use pat_0123456789abcdef now"}`, `{"note":"use [REDACTED] now"}`},
+		{`compat_mode pat_short`, `compat_mode pat_short`},
+		{`{"token_type":"Bearer","expires_in":3600}`, `{"token_type":"Bearer","expires_in":3600}`},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%s)\n got %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{
+		"Authorization":   {"Bearer abc"},
+		"X-Api-Key":       {"k"},
+		"Api-Key":         {"k"},
+		"X-Auth-Token":    {"t"},
+		"X-Client-Secret": {"s"},
+		"Content-Type":    {"application/json"},
+		"Idempotency-Key": {"5f0c"},
+		"X-Request-Id":    {"r1"},
+	}
+	got := redactHeaders(h)
+	for k := range h {
+		want := redacted
+		switch k {
+		case "Content-Type", "Idempotency-Key", "X-Request-Id":
+			want = h.Get(k)
+		}
+		if got.Get(k) != want {
+			t.Errorf("%s: got %q, want %q", k, got.Get(k), want)
+		}
+	}
+}
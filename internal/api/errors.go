@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIError is a non-2xx response from the TextOnly API.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "api error: %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " %s", e.Code)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+func (e *APIError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+func (e *APIError) IsNotFound() bool { return e.StatusCode == http.StatusNotFound }
+
+func (e *APIError) IsValidation() bool {
+	return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+}
+
+func (e *APIError) IsRateLimit() bool { return e.StatusCode == http.StatusTooManyRequests }
+
+func (e *APIError) IsServer() bool { return e.StatusCode >= 500 }
+
+// NetworkError wraps a failure to reach the API at all.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return "network error: " + e.Err.Error() }
+
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// RateLimitError reports that a request was held back without being sent:
+// the server's rate-limit budget is spent until Reset, which is later than
+// the call's deadline allows waiting.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: the request budget resets in %s, past the timeout; request not sent", time.Until(e.Reset).Round(time.Second))
+}
+
+// TimeoutError reports that a request or flow exceeded its deadline, as
+// opposed to failing outright.
+type TimeoutError struct {
+	Op    string
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	if e.After <= 0 {
+		return e.Op + " timed out"
+	}
+	return fmt.Sprintf("%s timed out after %s", e.Op, e.After)
+}
+
+// timeoutError classifies err as a connect or request timeout, or returns
+// nil when it is some other failure.
+func (c *Client) timeoutError(req *http.Request, ctx context.Context, err error) *TimeoutError {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+		return &TimeoutError{Op: "connect to " + req.URL.Host, After: c.connectTimeout}
+	}
+	var netErr net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &TimeoutError{Op: req.Method + " " + req.URL.Path, After: c.timeout}
+	}
+	return nil
+}
+
+// maxErrorBody bounds how much of a non-JSON error body ends up in a message.
+const maxErrorBody = 512
+
+// parseAPIError builds an APIError from a response, consuming its body.
+func parseAPIError(resp *http.Response) *APIError {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return NewAPIError(resp.StatusCode, resp.Header, b)
+}
+
+// NewAPIError builds an APIError from an already-read response body. The API
+// reports errors as either {"error": "code", "message": "..."} or
+// {"error": {"code": "...", "message": "..."}}; anything else is kept as
+// plain text.
+func NewAPIError(status int, header http.Header, b []byte) *APIError {
+	e := &APIError{StatusCode: status, RequestID: header.Get("X-Request-Id")}
+
+	var body struct {
+		Error     json.RawMessage `json:"error"`
+		Code      string          `json:"code"`
+		Message   string          `json:"message"`
+		RequestID string          `json:"request_id"`
+	}
+	if err := json.Unmarshal(b, &body); err == nil {
+		e.Code, e.Message = body.Code, body.Message
+		if e.RequestID == "" {
+			e.RequestID = body.RequestID
+		}
+		var s string
+		var obj struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Error, &s) == nil {
+			if e.Code == "" {
+				e.Code = s
+			} else if e.Message == "" {
+				e.Message = s
+			}
+		} else if json.Unmarshal(body.Error, &obj) == nil {
+			if obj.Code != "" {
+				e.Code = obj.Code
+			}
+			if obj.Message != "" {
+				e.Message = obj.Message
+			}
+		}
+	} else if text := strings.TrimSpace(string(b)); text != "" {
+		if len(text) > maxErrorBody {
+			text = text[:maxErrorBody] + "..."
+		}
+		e.Message = text
+	}
+	if e.Code == "" && e.Message == "" {
+		e.Message = strings.ToLower(http.StatusText(status))
+	}
+	return e
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		requestID string // X-Request-Id header
+		body      string
+		want      APIError
+	}{
+		{"flat", 404, "", `{"error":"not_found","message":"no such note"}`,
+			APIError{StatusCode: 404, Code: "not_found", Message: "no such note"}},
+		{"nested", 422, "", `{"error":{"code":"invalid_title","message":"title too long"}}`,
+			APIError{StatusCode: 422, Code: "invalid_title", Message: "title too long"}},
+		{"nested code only", 403, "", `{"error":{"code":"forbidden"}}`,
+			APIError{StatusCode: 403, Code: "forbidden"}},
+		{"code field with error text", 400, "", `{"code":"bad_request","error":"missing title"}`,
+			APIError{StatusCode: 400, Code: "bad_request", Message: "missing title"}},
+		{"request id in body", 500, "", `{"error":"internal","message":"boom","request_id":"r-1"}`,
+			APIError{StatusCode: 500, Code: "internal", Message: "boom", RequestID: "r-1"}},
+		{"request id header wins", 500, "h-1", `{"error":"internal","request_id":"r-1"}`,
+			APIError{StatusCode: 500, Code: "internal", RequestID: "h-1"}},
+		{"plain text", 502, "", "  Bad Gateway from proxy\n",
+			APIError{StatusCode: 502, Message: "Bad Gateway from proxy"}},
+		{"empty body", 429, "", "",
+			APIError{StatusCode: 429, Message: "too many requests"}},
+		{"unrelated JSON", 503, "", `{"status":"down"}`,
+			APIError{StatusCode: 503, Message: "service unavailable"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.requestID != "" {
+				h.Set("X-Request-Id", tt.requestID)
+			}
+			got := NewAPIError(tt.status, h, []byte(tt.body))
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAPIErrorTruncatesText(t *testing.T) {
+	got := NewAPIError(500, http.Header{}, []byte(strings.Repeat("x", maxErrorBody*2)))
+	if len(got.Message) != maxErrorBody+len("...") || !strings.HasSuffix(got.Message, "...") {
+		t.Errorf("message has %d bytes, want %d ending in ...", len(got.Message), maxErrorBody+3)
+	}
+}
+
+func TestAPIErrorClass(t *testing.T) {
+	tests := []struct {
+		status                                     int
+		auth, notFound, validation, rateLimit, srv bool
+	}{
+		{400, false, false, true, false, false},
+		{401, true, false, false, false, false},
+		{403, true, false, false, false, false},
+		{404, false, true, false, false, false},
+		{409, false, false, false, false, false},
+		{422, false, false, true, false, false},
+		{429, false, false, false, true, false},
+		{500, false, false, false, false, true},
+		{503, false, false, false, false, true},
+	}
+	for _, tt := range tests {
+		e := &APIError{StatusCode: tt.status}
+		got := [5]bool{e.IsAuth(), e.IsNotFound(), e.IsValidation(), e.IsRateLimit(), e.IsServer()}
+		want := [5]bool{tt.auth, tt.notFound, tt.validation, tt.rateLimit, tt.srv}
+		if got != want {
+			t.Errorf("%d: IsAuth, IsNotFound, IsValidation, IsRateLimit, IsServer = %v, want %v", tt.status, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type idempotencyKeyCtx struct{}
+
+// WithIdempotencyKey makes mutating requests issued with ctx carry key
+// instead of a generated one. Reusing a key across runs (e.g. a CI job id)
+// lets the server deduplicate a create that already succeeded.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKeyCtx{}, key)
+}
+
+// idempotencyKey returns the key for a request: the caller's, a fresh one
+// for POST/PATCH on API resources, or "" otherwise. Login and token
+// endpoints get no generated key: they trade single-use codes and refresh
+// tokens, which a replay to a server that ignores the header would burn, so
+// without a key they are sent once and never retried. The key is chosen
+// once per call so every retry replays the same value.
+func (c *Client) idempotencyKey(ctx context.Context, method, path string) string {
+	if method != http.MethodPost && method != http.MethodPatch {
+		return ""
+	}
+	if key, ok := ctx.Value(idempotencyKeyCtx{}).(string); ok {
+		return key
+	}
+	if isAuthEndpoint(strings.TrimPrefix(path, c.baseURL)) {
+		return ""
+	}
+	return newIdempotencyKey()
+}
+
+// authEndpoints are the path prefixes of the login and token exchange API.
+var authEndpoints = []string{"/cli/", "/oauth/", "/auth/"}
+
+func isAuthEndpoint(path string) bool {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	for _, prefix := range authEndpoints {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// newIdempotencyKey returns a random UUIDv4.
+func newIdempotencyKey() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
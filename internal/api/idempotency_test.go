@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	c := &Client{baseURL: "https://textonly.io/api"}
+	tests := []struct {
+		method, path string
+		callerKey    string
+		want         string // "generated", "" or the caller's key
+	}{
+		{"GET", "/notes", "", ""},
+		{"DELETE", "/notes/1", "", ""},
+		{"POST", "/notes", "", "generated"},
+		{"PATCH", "/notes/1", "", "generated"},
+		{"POST", "/tokens", "", "generated"},
+		{"POST", "notes", "", "generated"},
+		{"POST", "https://textonly.io/api/notes", "", "generated"},
+		{"POST", "/notes", "job-42", "job-42"},
+		{"GET", "/notes", "job-42", ""},
+		{"POST", "/cli/token/refresh", "", ""},
+		{"POST", "/cli/login/poll", "", ""},
+		{"POST", "/oauth/token", "", ""},
+		{"POST", "/auth/logout", "", ""},
+		{"POST", "https://textonly.io/api/cli/login/start", "", ""},
+		{"POST", "cli/login/start", "", ""},
+		{"POST", "/cli/token/refresh", "mine", "mine"},
+	}
+	for _, tt := range tests {
+		ctx := WithIdempotencyKey(context.Background(), tt.callerKey)
+		got := c.idempotencyKey(ctx, tt.method, tt.path)
+		switch tt.want {
+		case "generated":
+			if len(got) != 36 {
+				t.Errorf("%s %s: got %q, want a generated key", tt.method, tt.path, got)
+			}
+		default:
+			if got != tt.want {
+				t.Errorf("%s %s: got %q, want %q", tt.method, tt.path, got, tt.want)
+			}
+		}
+	}
+}
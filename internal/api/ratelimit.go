@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is the server-reported request budget for one API host.
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// rateLimiter is a token bucket fed by X-RateLimit-* headers. It is shared by
+// every Client in the process that talks to the same host, so concurrent
+// operations slow down together before the server starts returning 429s.
+type rateLimiter struct {
+	mu    sync.Mutex
+	known bool
+	state RateLimit
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*rateLimiter{}
+)
+
+func limiterFor(host string) *rateLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, ok := limiters[host]
+	if !ok {
+		l = &rateLimiter{}
+		limiters[host] = l
+	}
+	return l
+}
+
+// lowWater is the fraction of the budget below which requests are paced
+// evenly over the rest of the window instead of sent immediately.
+const lowWater = 10
+
+// wait takes a token, sleeping if the budget is exhausted or running low.
+// A sleep that would run past ctx's deadline is not started: the call
+// fails at once with a RateLimitError instead of a timeout.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.known {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if !l.state.Reset.IsZero() && !now.Before(l.state.Reset) {
+		// Window over; the next response tells us the new budget.
+		l.known = false
+		l.mu.Unlock()
+		return nil
+	}
+	var delay time.Duration
+	untilReset := l.state.Reset.Sub(now)
+	switch {
+	case l.state.Remaining <= 0:
+		delay = untilReset
+	case l.state.Limit > 0 && l.state.Remaining*lowWater < l.state.Limit:
+		delay = untilReset / time.Duration(l.state.Remaining+1)
+	}
+	if delay > 0 && !fitsDeadline(ctx, delay) {
+		reset := l.state.Reset
+		l.mu.Unlock()
+		return &RateLimitError{Reset: reset}
+	}
+	l.state.Remaining--
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, delay)
+}
+
+// update records the budget reported by a response.
+func (l *rateLimiter) update(h http.Header) {
+	limit, err1 := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err1 != nil || err2 != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known = true
+	l.state = RateLimit{Limit: limit, Remaining: remaining, Reset: parseReset(h.Get("X-RateLimit-Reset"))}
+}
+
+func (l *rateLimiter) snapshot() (RateLimit, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state, l.known
+}
+
+// parseReset accepts X-RateLimit-Reset as a Unix timestamp or as seconds
+// until the window resets.
+func parseReset(v string) time.Time {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}
+	}
+	if n > 1e9 {
+		return time.Unix(n, 0)
+	}
+	return time.Now().Add(time.Duration(n) * time.Second)
+}
+
+// RateLimit returns the last budget the server reported for this client's
+// host, if any request has seen one.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	if c.err != nil {
+		return RateLimit{}, false
+	}
+	return c.limiter.snapshot()
+}
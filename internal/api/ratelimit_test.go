@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseReset(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"", time.Time{}},
+		{"soon", time.Time{}},
+		{"-5", time.Time{}},
+		{"30", now.Add(30 * time.Second)},
+		{"0", now},
+		{"1700000000", time.Unix(1700000000, 0)},
+	}
+	for _, tt := range tests {
+		got := parseReset(tt.in)
+		if tt.want.IsZero() != got.IsZero() || got.Sub(tt.want).Abs() > time.Second {
+			t.Errorf("parseReset(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		known  bool
+		want   RateLimit
+	}{
+		{"no headers", nil, false, RateLimit{}},
+		{"limit only", map[string]string{"X-RateLimit-Limit": "100"}, false, RateLimit{}},
+		{"garbage", map[string]string{"X-RateLimit-Limit": "lots", "X-RateLimit-Remaining": "5"}, false, RateLimit{}},
+		{"without reset", map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "42"}, true, RateLimit{Limit: 100, Remaining: 42}},
+		{"with reset", map[string]string{"X-RateLimit-Limit": "10", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000000"}, true, RateLimit{Limit: 10, Reset: time.Unix(1700000000, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.header {
+				h.Set(k, v)
+			}
+			l := &rateLimiter{}
+			l.update(h)
+			got, known := l.snapshot()
+			if known != tt.known || got.Limit != tt.want.Limit || got.Remaining != tt.want.Remaining || !got.Reset.Equal(tt.want.Reset) {
+				t.Errorf("snapshot = %+v, %v; want %+v, %v", got, known, tt.want, tt.known)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		known     bool
+		state     RateLimit // Reset comes from resetIn
+		resetIn   time.Duration
+		timeout   time.Duration
+		min, max  time.Duration
+		wantErr   bool
+		remaining int
+	}{
+		{"unknown budget", false, RateLimit{}, 0, 0, 0, 20 * time.Millisecond, false, 0},
+		{"plenty left", true, RateLimit{Limit: 100, Remaining: 50}, time.Hour, 0, 0, 20 * time.Millisecond, false, 49},
+		{"window over", true, RateLimit{Limit: 100, Remaining: 0}, -time.Second, 0, 0, 20 * time.Millisecond, false, 0},
+		{"exhausted", true, RateLimit{Limit: 100, Remaining: 0}, 100 * time.Millisecond, 0, 80 * time.Millisecond, time.Second, false, -1},
+		{"running low", true, RateLimit{Limit: 100, Remaining: 1}, 200 * time.Millisecond, 0, 80 * time.Millisecond, 150 * time.Millisecond, false, 0},
+		{"reset past deadline", true, RateLimit{Limit: 100, Remaining: 0}, time.Hour, 100 * time.Millisecond, 0, 20 * time.Millisecond, true, 0},
+		{"reset before deadline", true, RateLimit{Limit: 100, Remaining: 0}, 50 * time.Millisecond, time.Second, 30 * time.Millisecond, 500 * time.Millisecond, false, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &rateLimiter{known: tt.known, state: tt.state}
+			if tt.resetIn != 0 {
+				l.state.Reset = time.Now().Add(tt.resetIn)
+			}
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			start := time.Now()
+			err := l.wait(ctx)
+			elapsed := time.Since(start)
+			var limitErr *RateLimitError
+			if tt.wantErr != errors.As(err, &limitErr) {
+				t.Fatalf("wait() = %v, want RateLimitError: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("wait() = %v", err)
+			}
+			if elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("waited %s, want %s..%s", elapsed, tt.min, tt.max)
+			}
+			if l.state.Remaining != tt.remaining {
+				t.Errorf("remaining = %d, want %d", l.state.Remaining, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWaitCanceled(t *testing.T) {
+	l := &rateLimiter{known: true, state: RateLimit{Limit: 10, Remaining: 0, Reset: time.Now().Add(time.Hour)}}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait() = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxBackoff caps a single exponential backoff step.
+	maxBackoff = 10 * time.Second
+	// maxRetryAfter is the longest Retry-After we are willing to wait out;
+	// anything longer is surfaced to the caller instead.
+	maxRetryAfter = 60 * time.Second
+)
+
+// shouldRetry reports whether the attempt should be repeated and, when the
+// server asked for it, how long to wait first. A zero wait means the caller
+// should fall back to exponential backoff.
+func (c *Client) shouldRetry(ctx context.Context, req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.maxRetries || !isIdempotent(req) || ctx.Err() != nil {
+		return 0, false
+	}
+	if err != nil {
+		return 0, isRetryableError(err)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		wait := retryAfter(resp)
+		if wait > maxRetryAfter {
+			return 0, false
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// isIdempotent reports whether a request can be safely replayed. POST and
+// PATCH only qualify when they carry an Idempotency-Key the server can use
+// to deduplicate.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost, http.MethodPatch:
+		return req.Header.Get("Idempotency-Key") != ""
+	}
+	return false
+}
+
+func isRetryableError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Connect timeouts are worth another try; the call's own deadline is
+	// not, and shouldRetry stops as soon as ctx is done.
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoff returns the exponential delay for attempt with +/-25% jitter.
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base << attempt
+	if delay <= 0 || delay > maxBackoff {
+		delay = maxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay/2))) - delay/4
+	return delay + jitter
+}
+
+// fitsDeadline reports whether waiting d still leaves time before ctx's
+// deadline. A longer wait could only end in a timeout, hiding the response
+// (a 429, say) that asked for it.
+func fitsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
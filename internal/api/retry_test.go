@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		min, max time.Duration
+	}{
+		{"absent", "", 0, 0},
+		{"seconds", "7", 7 * time.Second, 7 * time.Second},
+		{"zero", "0", 0, 0},
+		{"negative", "-3", 0, 0},
+		{"garbage", "soon", 0, 0},
+		{"http date", time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+			if got := retryAfter(resp); got < tt.min || got > tt.max {
+				t.Errorf("retryAfter(%q) = %s, want %s..%s", tt.header, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	c := &Client{maxRetries: 3}
+	tests := []struct {
+		name       string
+		method     string
+		idemKey    bool
+		status     int
+		retryAfter string
+		err        error
+		attempt    int
+		want       bool
+		wait       time.Duration
+	}{
+		{"get 503", "GET", false, 503, "", nil, 0, true, 0},
+		{"get 429 with retry-after", "GET", false, 429, "2", nil, 0, true, 2 * time.Second},
+		{"get 502", "GET", false, 502, "", nil, 1, true, 0},
+		{"get 504", "GET", false, 504, "", nil, 2, true, 0},
+		{"get 500", "GET", false, 500, "", nil, 0, false, 0},
+		{"get 404", "GET", false, 404, "", nil, 0, false, 0},
+		{"attempts used up", "GET", false, 503, "", nil, 3, false, 0},
+		{"retry-after too long", "GET", false, 503, "120", nil, 0, false, 0},
+		{"delete 503", "DELETE", false, 503, "", nil, 0, true, 0},
+		{"post without key", "POST", false, 503, "", nil, 0, false, 0},
+		{"post with key", "POST", true, 503, "", nil, 0, true, 0},
+		{"patch with key", "PATCH", true, 429, "", nil, 0, true, 0},
+		{"connection reset", "GET", false, 0, "", syscall.ECONNRESET, 0, true, 0},
+		{"unexpected eof", "PUT", false, 0, "", io.ErrUnexpectedEOF, 0, true, 0},
+		{"other error", "GET", false, 0, "", errors.New("tls: bad certificate"), 0, false, 0},
+		{"post reset without key", "POST", false, 0, "", syscall.ECONNRESET, 0, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://api.test/notes", nil)
+			if tt.idemKey {
+				req.Header.Set("Idempotency-Key", "k")
+			}
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status, Header: http.Header{}}
+				if tt.retryAfter != "" {
+					resp.Header.Set("Retry-After", tt.retryAfter)
+				}
+			}
+			wait, ok := c.shouldRetry(context.Background(), req, resp, tt.err, tt.attempt)
+			if ok != tt.want || wait != tt.wait {
+				t.Errorf("shouldRetry = %s, %v; want %s, %v", wait, ok, tt.wait, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "http://api.test/notes", nil)
+	if _, ok := c.shouldRetry(ctx, req, &http.Response{StatusCode: 503, Header: http.Header{}}, nil, 0); ok {
+		t.Error("retried after the context was cancelled")
+	}
+}
+
+// TestTimeoutCoversRetries checks that Timeout is one deadline for the
+// whole call rather than a fresh one per attempt.
+func TestTimeoutCoversRetries(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		baseURL:    ts.URL,
+		httpClient: &http.Client{},
+		maxRetries: 5,
+		retryBase:  time.Millisecond,
+		timeout:    300 * time.Millisecond,
+		limiter:    &rateLimiter{},
+	}
+	start := time.Now()
+	err := c.DoContext(context.Background(), "GET", "/slow", nil, false, nil)
+	elapsed := time.Since(start)
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("got %v, want a TimeoutError", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("took %s with a 300ms timeout", elapsed)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server saw %d attempts, want 1", n)
+	}
+}
+
+// TestRetryAfterPastDeadline checks that a Retry-After longer than the time
+// left surfaces the 429 instead of sleeping into a timeout.
+func TestRetryAfterPastDeadline(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "30")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"rate_limited","message":"slow down"}`))
+	}))
+	defer ts.Close()
+
+	c := &Client{
+		baseURL:    ts.URL,
+		httpClient: &http.Client{},
+		maxRetries: 3,
+		retryBase:  time.Millisecond,
+		timeout:    2 * time.Second,
+		limiter:    &rateLimiter{},
+	}
+	start := time.Now()
+	err := c.DoContext(context.Background(), "GET", "/me", nil, false, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsRateLimit() {
+		t.Fatalf("got %v, want a rate-limit APIError", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("took %s; the Retry-After should not have been waited out", elapsed)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server saw %d attempts, want 1", n)
+	}
+}
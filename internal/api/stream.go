@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"unicode/utf8"
+)
+
+// StreamBody is a JSON request body whose largest field is streamed from a
+// reader instead of held in memory. Pass it as the body to DoContext:
+//
+//	&api.StreamBody{Fields: params, Field: "content", Content: f}
+//
+// produces the same JSON as marshalling params with content set to the text
+// of f. When Content is an io.Seeker the body is rewound for retries;
+// otherwise the request is sent exactly once.
+type StreamBody struct {
+	// Fields is marshalled as a JSON object; its Field key, if any, is dropped.
+	Fields any
+	// Field is the key whose string value is read from Content.
+	Field   string
+	Content io.Reader
+	// Gzip compresses the request body and sets Content-Encoding.
+	Gzip bool
+	// Progress, if set, is called with the number of Content bytes read so far.
+	// It runs on another goroutine, but never after DoContext returns.
+	Progress func(read int64)
+}
+
+func (sb *StreamBody) header() http.Header {
+	if !sb.Gzip {
+		return nil
+	}
+	return http.Header{"Content-Encoding": {"gzip"}}
+}
+
+func (sb *StreamBody) requestBody() (*requestBody, error) {
+	if sb.Field == "" || sb.Content == nil {
+		return nil, fmt.Errorf("stream body: Field and Content are required")
+	}
+	fields, err := objectFields(sb.Fields, sb.Field)
+	if err != nil {
+		return nil, err
+	}
+	seeker, replayable := sb.Content.(io.Seeker)
+	// prev and done belong to the last attempt's encoder. The transport
+	// closes a request body asynchronously, so before rewinding Content the
+	// old encoder is stopped and waited for; otherwise its last buffered
+	// Read could move the offset after the Seek and drop the start of the
+	// next attempt's content.
+	var prev *io.PipeReader
+	var done chan struct{}
+	stop := func(err error) {
+		if prev != nil {
+			prev.CloseWithError(err)
+			<-done
+		}
+	}
+	return &requestBody{
+		replayable: replayable,
+		open: func() (io.Reader, error) {
+			if prev != nil {
+				if !replayable {
+					return nil, fmt.Errorf("stream body: content cannot be re-read")
+				}
+				stop(errBodyReopened)
+				if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+					return nil, err
+				}
+			}
+			pr, pw := io.Pipe()
+			prev, done = pr, make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				pw.CloseWithError(sb.encode(pw, fields))
+			}(done)
+			return pr, nil
+		},
+		// The encoder calls Progress, so it must be finished before the
+		// caller draws the final state.
+		close: func() { stop(errBodyClosed) },
+	}, nil
+}
+
+var (
+	errBodyReopened = errors.New("stream body: superseded by a retry")
+	errBodyClosed   = errors.New("stream body: request finished")
+)
+
+// objectFields marshals v to a JSON object and returns its members in key
+// order, minus skip.
+func objectFields(v any, skip string) ([][2][]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("stream body: fields must encode as a JSON object: %w", err)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		if k != skip {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := make([][2][]byte, 0, len(keys))
+	for _, k := range keys {
+		kb, _ := json.Marshal(k)
+		out = append(out, [2][]byte{kb, m[k]})
+	}
+	return out, nil
+}
+
+func (sb *StreamBody) encode(w io.Writer, fields [][2][]byte) error {
+	var gz *gzip.Writer
+	if sb.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	bw := bufio.NewWriterSize(w, 32<<10)
+	bw.WriteByte('{')
+	for _, f := range fields {
+		bw.Write(f[0])
+		bw.WriteByte(':')
+		bw.Write(f[1])
+		bw.WriteByte(',')
+	}
+	key, _ := json.Marshal(sb.Field)
+	bw.Write(key)
+	bw.WriteString(`:"`)
+
+	var src io.Reader = sb.Content
+	if sb.Progress != nil {
+		src = &progressReader{r: src, fn: sb.Progress}
+	}
+	if err := writeJSONString(bw, bufio.NewReaderSize(src, 32<<10)); err != nil {
+		return err
+	}
+	bw.WriteString(`"}`)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// writeJSONString escapes the text read from r as the inside of a JSON
+// string, matching encoding/json (invalid UTF-8 becomes U+FFFD).
+func writeJSONString(w *bufio.Writer, r *bufio.Reader) error {
+	for {
+		c, size, err := r.ReadRune()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// bufio.Writer errors are sticky, so checking the last write of each
+		// rune is enough to stop as soon as the request is abandoned.
+		var werr error
+		switch {
+		case c == utf8.RuneError && size == 1:
+			_, werr = w.WriteString(`\ufffd`)
+		case c == '"' || c == '\\':
+			w.WriteByte('\\')
+			_, werr = w.WriteRune(c)
+		case c == '\n':
+			_, werr = w.WriteString(`\n`)
+		case c == '\r':
+			_, werr = w.WriteString(`\r`)
+		case c == '\t':
+			_, werr = w.WriteString(`\t`)
+		case c < 0x20 || c == '<' || c == '>' || c == '&' || c == '\u2028' || c == '\u2029':
+			w.WriteString(`\u`)
+			for shift := 12; shift >= 0; shift -= 4 {
+				werr = w.WriteByte(hexDigits[(c>>uint(shift))&0xf])
+			}
+		default:
+			_, werr = w.WriteRune(c)
+		}
+		if werr != nil {
+			return werr
+		}
+	}
+}
+
+type progressReader struct {
+	r  io.Reader
+	n  int64
+	fn func(int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	p.fn(p.n)
+	return n, err
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteJSONStringMatchesEncodingJSON(t *testing.T) {
+	inputs := []string{
+		"",
+		"plain ascii",
+		"quotes \" and \\ backslashes",
+		"line\nbreaks\r\nand\ttabs",
+		"control \x00\x01\x1f\x7f chars",
+		"<script>&amp;</script>",
+		"unicode héllo 世界 🙂",
+		"separators   and  ",
+		"invalid \xff\xfe utf-8 \xc3",
+		"truncated rune at end \xe4\xb8",
+		strings.Repeat("long line with ü ", 10000),
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		b := make([]byte, rng.Intn(200))
+		rng.Read(b)
+		inputs = append(inputs, string(b))
+	}
+	for _, in := range inputs {
+		want, _ := json.Marshal(in)
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		if err := writeJSONString(w, bufio.NewReader(strings.NewReader(in))); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		// Escaping of invalid UTF-8 and \b, \f differs between Go releases,
+		// so compare what the strings decode to.
+		got := `"` + buf.String() + `"`
+		var gotS, wantS string
+		if err := json.Unmarshal([]byte(got), &gotS); err != nil {
+			t.Errorf("writeJSONString(%q) = %s: %v", in, got, err)
+			continue
+		}
+		json.Unmarshal(want, &wantS)
+		if gotS != wantS {
+			t.Errorf("writeJSONString(%q)\n got %s\nwant %s", in, got, want)
+		}
+	}
+}
+
+func TestStreamBodyMatchesMarshal(t *testing.T) {
+	fields := map[string]any{"title": "t", "public": true, "content": "ignored"}
+	content := "first line\n\"second\" <line>"
+	for _, gz := range []bool{false, true} {
+		sb := &StreamBody{Fields: fields, Field: "content", Content: strings.NewReader(content), Gzip: gz}
+		rb, err := sb.requestBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := rb.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := readBody(t, r, gz)
+		want, _ := json.Marshal(map[string]any{"title": "t", "public": true, "content": content})
+		if !jsonEqual(t, got, want) {
+			t.Errorf("gzip=%v: got %s, want %s", gz, got, want)
+		}
+	}
+}
+
+// TestStreamBodyReopen abandons an attempt partway through, as a failed
+// request does, and checks that the next attempt still sends everything.
+func TestStreamBodyReopen(t *testing.T) {
+	content := strings.Repeat("0123456789abcdef", 64<<10)
+	sb := &StreamBody{Field: "content", Content: strings.NewReader(content)}
+	rb, err := sb.requestBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		r, err := rb.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadFull(r, make([]byte, 1000*attempt+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := rb.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Content string }
+	if err := json.Unmarshal(readBody(t, r, false), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != content {
+		t.Errorf("retried body has %d content bytes, want %d", len(got.Content), len(content))
+	}
+}
+
+func TestStreamBodyNotReplayable(t *testing.T) {
+	sb := &StreamBody{Field: "content", Content: io.MultiReader(strings.NewReader("x"))}
+	rb, err := sb.requestBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rb.replayable {
+		t.Fatal("a plain reader must not be replayable")
+	}
+	if _, err := rb.open(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rb.open(); err == nil {
+		t.Fatal("second open of a plain reader: want error")
+	}
+}
+
+// earlyReply answers a request after reading the first bytes of its body
+// and goes on reading the rest in the background, as http.Transport does
+// when a server replies before the upload is complete.
+type earlyReply struct{}
+
+func (earlyReply) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, err := io.ReadFull(req.Body, make([]byte, 1000)); err != nil {
+		return nil, err
+	}
+	go func() {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{}`)),
+		Request:    req,
+	}, nil
+}
+
+// TestStreamBodyProgressStops checks that Progress is never called after
+// DoContext returns, so callers can draw the final state without racing
+// the encoder.
+func TestStreamBodyProgressStops(t *testing.T) {
+	c := &Client{baseURL: "http://api.test", httpClient: &http.Client{Transport: earlyReply{}}, limiter: &rateLimiter{}}
+	var returned, late atomic.Bool
+	sb := &StreamBody{
+		Field:   "content",
+		Content: strings.NewReader(strings.Repeat("x", 64<<20)),
+		Progress: func(int64) {
+			if returned.Load() {
+				late.Store(true)
+			}
+		},
+	}
+	if err := c.DoContext(context.Background(), "POST", "/notes", sb, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	returned.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	if late.Load() {
+		t.Error("Progress called after DoContext returned")
+	}
+}
+
+func readBody(t *testing.T, r io.Reader, gz bool) []byte {
+	t.Helper()
+	if gz {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var x, y any
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	xb, _ := json.Marshal(x)
+	yb, _ := json.Marshal(y)
+	return bytes.Equal(xb, yb)
+}
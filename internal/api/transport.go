@@ -0,0 +1,103 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// baseTransport is captured before anything (such as the self-updater) swaps
+// http.DefaultTransport for one of ours.
+var baseTransport = http.DefaultTransport.(*http.Transport)
+
+var insecureWarning sync.Once
+
+// NewTransport returns the transport for talking to the API:
+// proxy from the environment plus the TLS settings from config (ca_file,
+// client_cert/client_key, tls_min_version, insecure_skip_verify).
+func NewTransport() (*http.Transport, error) {
+	return newTransport(true)
+}
+
+// NewPublicTransport returns the transport for hosts other than the API,
+// such as GitHub for updates. Proxy, ca_file, tls_min_version and
+// connect_timeout apply; the client certificate and insecure_skip_verify are
+// meant for the API only and never do.
+func NewPublicTransport() (*http.Transport, error) {
+	return newTransport(false)
+}
+
+func newTransport(forAPI bool) (*http.Transport, error) {
+	tlsCfg, err := tlsConfig(forAPI)
+	if err != nil {
+		return nil, err
+	}
+	transport := baseTransport.Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.TLSClientConfig = tlsCfg
+	if d := config.ConnectTimeout(); d > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: d, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = d
+	}
+	return transport, nil
+}
+
+// tlsVersions are the accepted tls_min_version values. TLS 1.0 and 1.1 are
+// deprecated (RFC 8996) and cannot be selected.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func tlsConfig(forAPI bool) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if v := config.TLSMinVersion(); v != "" {
+		min, ok := tlsVersions[v]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls_min_version %q: use 1.2 or 1.3", v)
+		}
+		cfg.MinVersion = min
+	}
+	if caFile := config.CAFile(); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file: no PEM certificates found in %s", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if !forAPI {
+		return cfg, nil
+	}
+	certFile, keyFile := config.ClientCert(), config.ClientKey()
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("client_cert and client_key must be set together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if config.InsecureSkipVerify() {
+		insecureWarning.Do(func() {
+			fmt.Fprintln(os.Stderr, "warning: TLS certificate verification is disabled (insecure_skip_verify); connections can be intercepted")
+		})
+		cfg.InsecureSkipVerify = true
+	}
+	return cfg, nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestTLSMinVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint16
+		wantErr bool
+	}{
+		{"", tls.VersionTLS12, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.0", 0, true},
+		{"1.1", 0, true},
+		{"tls1.3", 0, true},
+	}
+	t.Cleanup(func() { viper.Set("tls_min_version", "") })
+	for _, tt := range tests {
+		viper.Set("tls_min_version", tt.value)
+		cfg, err := tlsConfig(true)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("tls_min_version %q: got MinVersion %#x, want an error", tt.value, cfg.MinVersion)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("tls_min_version %q: %v", tt.value, err)
+		} else if cfg.MinVersion != tt.want {
+			t.Errorf("tls_min_version %q: MinVersion %#x, want %#x", tt.value, cfg.MinVersion, tt.want)
+		}
+	}
+}
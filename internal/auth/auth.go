@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -14,61 +18,198 @@ import (
 
 	"github.com/textonlyio/textonly-cli/internal/api"
 	"github.com/textonlyio/textonly-cli/internal/config"
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+	"github.com/textonlyio/textonly-cli/pkg/ui"
 )
 
-const keyringService = "textonly-cli"
+// LoginOptions tunes Login.
+type LoginOptions struct {
+	// NoOpen leaves opening the verification page to the user.
+	NoOpen bool
+	// CodeOnly prints the user code as a single JSON line on stdout instead
+	// of instructions, for tools that present it themselves, then waits for
+	// approval as usual. Success is reported by the exit status.
+	CodeOnly bool
+	// Web uses the browser redirect flow, falling back to the device flow
+	// when no browser or loopback port is available.
+	Web bool
+}
+
+var (
+	// ErrAccessDenied means the user rejected the login in the browser.
+	ErrAccessDenied = errors.New("login was denied in the browser")
+	// ErrLoginExpired means the device code expired before it was approved.
+	ErrLoginExpired = errors.New("login code expired before it was approved; run `to login` again")
+)
+
+const (
+	// defaultDeviceCodeLifetime applies when the server omits expires_in.
+	defaultDeviceCodeLifetime = 15 * time.Minute
+	// defaultPollInterval and slowDownStep are the RFC 8628 defaults: poll
+	// every 5s unless told otherwise, and add 5s whenever the server answers
+	// slow_down.
+	defaultPollInterval = 5 * time.Second
+	slowDownStep        = 5 * time.Second
+)
+
+// deviceCode is the payload printed by --code-only.
+type deviceCode struct {
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
 
-func Login(noOpen bool) error {
-	c := api.New(LoadToken)
+// Login runs the OAuth device authorization flow (RFC 8628), or the browser
+// flow with opts.Web, and stores the resulting credentials for the active
+// profile.
+func Login(ctx context.Context, opts LoginOptions) error {
+	if err := checkCredentialStore(); err != nil { return err }
+	if opts.Web {
+		err := webLogin(ctx)
+		if !errors.Is(err, errNoBrowser) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Cannot use the browser login (%v); using a login code instead.\n", err)
+	}
+	c := api.New(nil)
 	var startResp struct {
-		DeviceCode      string `json:"device_code"`
-		UserCode        string `json:"user_code"`
-		VerificationURI string `json:"verification_uri"`
-		Interval        int    `json:"interval"`
-		ExpiresIn       int    `json:"expires_in"`
-	}
-	if err := c.Do("POST", "/cli/login/start", nil, false, &startResp); err != nil { return err }
-	if startResp.Interval <= 0 { startResp.Interval = 3 }
-	fmt.Printf("Go to %s and enter code: %s\n", startResp.VerificationURI, startResp.UserCode)
-	if !noOpen { _ = openBrowser(startResp.VerificationURI) }
-
-	deadline := time.Now().Add(time.Duration(startResp.ExpiresIn) * time.Second)
+		deviceCode
+		DeviceCode string `json:"device_code"`
+	}
+	if err := c.DoContext(ctx, "POST", "/cli/login/start", nil, false, &startResp); err != nil { return err }
+	code := startResp.deviceCode
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 { interval = defaultPollInterval }
+	lifetime := time.Duration(code.ExpiresIn) * time.Second
+	if lifetime <= 0 { lifetime = defaultDeviceCodeLifetime }
+	code.Interval, code.ExpiresIn = int(interval/time.Second), int(lifetime/time.Second)
+
+	if opts.CodeOnly {
+		if err := json.NewEncoder(os.Stdout).Encode(code); err != nil { return err }
+	} else {
+		fmt.Printf("Go to %s and enter code: %s\n", code.VerificationURI, code.UserCode)
+		if !opts.NoOpen {
+			u := code.VerificationURIComplete
+			if u == "" { u = code.VerificationURI }
+			_ = openBrowser(u)
+		}
+	}
+
+	wait := lifetime
+	if lt := config.LoginTimeout(); lt > 0 && lt < wait {
+		wait = lt
+	}
+	deadline := time.Now().Add(wait)
 	for time.Now().Before(deadline) {
 		var pollResp struct {
-			Status      string `json:"status"`
-			AccessToken string `json:"access_token"`
+			Status string `json:"status"`
+			tokenResponse
 		}
-		err := c.Do("POST", "/cli/login/poll", map[string]string{"device_code": startResp.DeviceCode}, false, &pollResp)
-		if err == nil {
-			if pollResp.AccessToken != "" {
-				if err := SaveToken(pollResp.AccessToken); err != nil { return err }
+		err := c.DoContext(ctx, "POST", "/cli/login/poll", map[string]string{"device_code": startResp.DeviceCode}, false, &pollResp)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// RFC 8628 servers report pending states as 400 {"error": "..."}
+		// rather than in a 200 status field; accept both.
+		status := pollResp.Status
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) {
+			status = apiErr.Code
+		}
+		switch {
+		case err == nil && pollResp.AccessToken != "":
+			if err := saveCredentials(pollResp.credentials()); err != nil { return err }
+			if err := rememberProfile(); err != nil { return err }
+			if opts.CodeOnly {
+				fmt.Fprintln(os.Stderr, "login successful")
+			} else {
 				fmt.Println("login successful")
-				return nil
 			}
+			return nil
+		case err != nil && apiErr == nil:
+			// Network trouble; keep polling until the deadline.
+		case status == "authorization_pending" || (err == nil && status == ""):
+		case status == "slow_down":
+			interval += slowDownStep
+		case status == "access_denied":
+			return ErrAccessDenied
+		case status == "expired_token":
+			return ErrLoginExpired
+		case err != nil:
+			return err
+		default:
+			return fmt.Errorf("unexpected login status %q", status)
+		}
+		// Never poll faster than the interval; jitter only lengthens it.
+		sleep := interval + time.Duration(rand.Int63n(int64(interval/4)+1))
+		if left := time.Until(deadline); sleep > left {
+			sleep = left
 		}
-		sleep := time.Duration(startResp.Interval) * time.Second
-		j := sleep / 4
-		sleep = sleep + (time.Duration(int64(time.Now().UnixNano())%int64(2*j)) - j)
-		time.Sleep(sleep)
+		if err := sleepContext(ctx, sleep); err != nil {
+			return err
+		}
+	}
+	if wait < lifetime {
+		return &api.TimeoutError{Op: "login", After: wait}
 	}
-	return errors.New("login timed out")
+	return ErrLoginExpired
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// maxTokenInput bounds how much of stdin LoginWithToken reads.
+const maxTokenInput = 64 << 10
+
+// LoginWithToken reads a personal access token from r, checks it against
+// /me and stores it for the active profile.
+func LoginWithToken(ctx context.Context, r io.Reader) error {
+	if src, ok := overrideSource(); ok {
+		return fmt.Errorf("%s takes precedence over stored credentials; remove it to log in with a stored token", src)
+	}
+	if err := checkCredentialStore(); err != nil { return err }
+	b, err := io.ReadAll(io.LimitReader(r, maxTokenInput))
+	if err != nil { return err }
+	tok := strings.TrimSpace(string(b))
+	if tok == "" { return errors.New("no token on stdin") }
+	if strings.ContainsAny(tok, " \t\r\n") { return errors.New("expected a single token on stdin") }
+
+	c := textonly.NewClient(api.New(func() (string, error) { return tok, nil }))
+	me, err := c.Users.Me(ctx)
+	if err != nil { return err }
+	if err := SaveToken(tok); err != nil { return err }
+	if err := rememberProfile(); err != nil { return err }
+	fmt.Fprintf(os.Stderr, "logged in as %s\n", me.Email)
+	return nil
 }
 
-func Logout() error {
+// Logout revokes and removes the stored credentials of the active profile.
+// Tokens from --token, TO_TOKEN and the like are left alone.
+func Logout(ctx context.Context) error {
 	tok, _ := LoadToken()
 	if tok != "" {
 		c := api.New(LoadToken)
-		_ = c.Do("POST", "/auth/logout", nil, true, nil)
+		_ = c.DoContext(ctx, "POST", "/auth/logout", nil, true, nil)
 	}
 	return ClearToken()
 }
 
-func WhoAmI(asJSON bool) error {
-	c := api.New(LoadToken)
-	var me map[string]any
-	if err := c.Do("GET", "/me", nil, true, &me); err != nil { return err }
-	if asJSON { b,_ := json.MarshalIndent(me, "", "  "); fmt.Println(string(b)); return nil }
-	if v, ok := me["email"].(string); ok { fmt.Println(v); return nil }
+func WhoAmI(ctx context.Context, asJSON bool) error {
+	me, err := textonly.NewClient(NewClient()).Users.Me(ctx)
+	if err != nil { return err }
+	if asJSON && len(me.Raw) > 0 { ui.PrintJSON(me.Raw); return nil }
+	if asJSON { ui.PrintJSON(me); return nil }
+	if me.Email != "" { fmt.Println(me.Email); return nil }
 	fmt.Println("authenticated")
 	return nil
 }
@@ -82,33 +223,129 @@ func openBrowser(url string) error {
 	return fmt.Errorf("please open %s manually", url)
 }
 
-func tokenFilePath() string { return filepath.Join(config.Dir(), "token") }
+// rememberProfile records the profile just logged in to, with the API it
+// used, and makes it the active one. The default profile is never repointed:
+// a one-off `to login --api ...` must not send later plain commands to that
+// API, so its credentials are only used while --api (or TO_API) names it.
+func rememberProfile() error {
+	p := config.Profile()
+	if p != config.DefaultProfile {
+		if err := config.SaveProfile(p, config.APIBaseURL()); err != nil { return err }
+		return config.UseProfile(p)
+	}
+	usual := config.ProfileAPI(p)
+	if usual == "" { usual = config.DefaultAPIBaseURL() }
+	if target := config.APIBaseURL(); target != usual {
+		fmt.Fprintf(os.Stderr, "note: this login is used only with --api %s; run `to login --profile NAME --api %s` to keep a profile for it\n", target, target)
+	}
+	return config.UseProfile(p)
+}
 
-func SaveToken(token string) error {
-	r, err := keyring.Open(keyring.Config{ServiceName: keyringService})
-	if err == nil {
-		if e := r.Set(keyring.Item{Key: "token", Data: []byte(token)}); e == nil {
-			return nil
-		}
+// tokenKey is the keyring key for a profile's credentials on one API.
+// Credentials are scoped to the normalized API base URL so a token is only
+// ever sent to the server that issued it. The default profile on the hosted
+// API keeps the key used before profiles existed so existing logins carry
+// over.
+func tokenKey(profile, apiURL string) string {
+	if profile == config.DefaultProfile && apiURL == config.BuiltinAPIBaseURL {
+		return "token"
+	}
+	return "token:" + profile + "@" + apiURL
+}
+
+// legacyTokenPath is where releases without keyring_backend wrote the token
+// in plain text when no keyring was available. It is only read, to migrate
+// such logins into the keyring.
+func legacyTokenPath(profile, apiURL string) string {
+	if tokenKey(profile, apiURL) == "token" {
+		return filepath.Join(config.Dir(), "token")
+	}
+	return filepath.Join(config.Dir(), "tokens", profile, url.QueryEscape(apiURL))
+}
+
+// SaveToken stores token for the active profile and API.
+func SaveToken(token string) error { return saveCredentials(Credentials{AccessToken: token}) }
+
+func saveCredentials(cr Credentials) error {
+	err := storeCredentials(config.Profile(), config.APIBaseURL(), cr)
+	setActive(Credentials{}, Source{})
+	return err
+}
+
+func storeCredentials(profile, apiURL string, cr Credentials) error {
+	data, err := cr.marshal()
+	if err != nil { return err }
+	r, _, err := openKeyring()
+	if err != nil { return fmt.Errorf("cannot store credentials: %w", err) }
+	if err := r.Set(keyring.Item{Key: tokenKey(profile, apiURL), Label: "TextOnly CLI (" + profile + ")", Data: data}); err != nil {
+		return fmt.Errorf("cannot store credentials: %w", err)
 	}
-	if err := os.MkdirAll(config.Dir(), 0o755); err != nil { return err }
-	return os.WriteFile(tokenFilePath(), []byte(token), 0o600)
+	return nil
+}
+
+// LoadToken returns the stored token for the active profile and API.
+func LoadToken() (string, error) { return LoadTokenFor(config.Profile(), config.APIBaseURL()) }
+
+// LoadTokenFor returns the token stored for profile on apiURL, which must
+// already be normalized.
+func LoadTokenFor(profile, apiURL string) (string, error) {
+	cr, err := loadCredentials(profile, apiURL)
+	return cr.AccessToken, err
+}
+
+func loadCredentials(profile, apiURL string) (Credentials, error) {
+	cr, _, err := locateCredentials(profile, apiURL)
+	return cr, err
+}
+
+// Source says where a credential was found. Kind is one of "flag", "env",
+// "token_file", "token_command", "keyring" or "file" (a plain-text login
+// from an older version); Detail names the flag, variable, path, command or
+// keyring backend.
+type Source struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
 }
 
-func LoadToken() (string, error) {
-	r, err := keyring.Open(keyring.Config{ServiceName: keyringService})
+func (s Source) String() string { return s.Kind + " (" + s.Detail + ")" }
+
+func locateCredentials(profile, apiURL string) (Credentials, Source, error) {
+	r, backend, err := openKeyring()
 	if err == nil {
-		it, e := r.Get("token")
-		if e == nil { return string(it.Data), nil }
+		it, e := r.Get(tokenKey(profile, apiURL))
+		if e == nil {
+			cr, err := parseCredentials(it.Data)
+			return cr, Source{Kind: "keyring", Detail: backendSource(backend)}, err
+		}
+		if !errors.Is(e, keyring.ErrKeyNotFound) {
+			return Credentials{}, Source{}, keyringReadError(backend, e)
+		}
+	}
+	path := legacyTokenPath(profile, apiURL)
+	b, e := os.ReadFile(path)
+	if e != nil && err != nil {
+		return Credentials{}, Source{}, fmt.Errorf("cannot read credentials: %w", err)
+	}
+	if e != nil {
+		return Credentials{}, Source{}, fmt.Errorf("%w to %s (profile %s)", api.ErrNoToken, apiURL, profile)
+	}
+	cr, e := parseCredentials(b)
+	if e != nil {
+		return Credentials{}, Source{}, e
+	}
+	// Move plain-text logins into the keyring when one is available.
+	if err == nil && storeCredentials(profile, apiURL, cr) == nil {
+		_ = os.Remove(path)
+		return cr, Source{Kind: "keyring", Detail: backendSource(backend)}, nil
 	}
-	b, err := os.ReadFile(tokenFilePath())
-	if err != nil { return "", errors.New("not logged in") }
-	return strings.TrimSpace(string(b)), nil
+	return cr, Source{Kind: "file", Detail: path}, nil
 }
 
+// ClearToken removes the token for the active profile and API.
 func ClearToken() error {
-	r, err := keyring.Open(keyring.Config{ServiceName: keyringService})
-	if err == nil { _ = r.Remove("token") }
-	_ = os.Remove(tokenFilePath())
+	profile, apiURL := config.Profile(), config.APIBaseURL()
+	r, _, err := openKeyring()
+	if err == nil { _ = r.Remove(tokenKey(profile, apiURL)) }
+	_ = os.Remove(legacyTokenPath(profile, apiURL))
 	return nil
 }
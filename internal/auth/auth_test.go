@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// TestMain keeps the config file and keyring of the tests in a scratch
+// directory, using the encrypted file store so no desktop keyring is
+// touched.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "to-auth-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for k, v := range map[string]string{
+		"HOME":                dir,
+		"XDG_CONFIG_HOME":     dir,
+		"XDG_CACHE_HOME":      dir,
+		"TO_KEYRING_BACKEND":  "file",
+		"TO_KEYRING_PASSWORD": "test",
+	} {
+		os.Setenv(k, v)
+	}
+	for _, k := range []string{"TO_API", "TO_PROFILE", "TO_TOKEN", "TO_TOKEN_FILE", "TO_TOKEN_COMMAND"} {
+		os.Unsetenv(k)
+	}
+	_ = config.Init()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// setConfig sets key for the rest of the test.
+func setConfig(t *testing.T, key, value string) {
+	t.Helper()
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, "") })
+}
+
+// reply is one canned answer of deviceServer.
+type reply struct {
+	status int
+	body   string
+}
+
+// deviceServer answers /cli/login/start with a code that expires after
+// lifetime seconds, and each /cli/login/poll with the next of polls,
+// repeating the last one.
+func deviceServer(t *testing.T, lifetime int, polls ...reply) string {
+	t.Helper()
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/cli/login/start":
+			json.NewEncoder(w).Encode(map[string]any{
+				"device_code": "dc", "user_code": "ABCD-EFGH", "verification_uri": "http://example.test/activate",
+				"expires_in": lifetime, "interval": 1,
+			})
+		case "/cli/login/poll":
+			mu.Lock()
+			p := polls[0]
+			if len(polls) > 1 {
+				polls = polls[1:]
+			}
+			mu.Unlock()
+			w.WriteHeader(p.status)
+			w.Write([]byte(p.body))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestLoginDeviceFlow(t *testing.T) {
+	approved := reply{200, `{"status":"approved","access_token":"acc","refresh_token":"ref","expires_in":3600}`}
+	tests := []struct {
+		name     string
+		lifetime int
+		timeout  string
+		polls    []reply
+		check    func(error) bool
+	}{
+		{"approved", 60, "", []reply{approved}, func(err error) bool { return err == nil }},
+		{"pending in status field", 60, "", []reply{{200, `{"status":"authorization_pending"}`}, approved},
+			func(err error) bool { return err == nil }},
+		{"pending as RFC 8628 error", 60, "", []reply{{400, `{"error":"authorization_pending"}`}, approved},
+			func(err error) bool { return err == nil }},
+		{"denied", 60, "", []reply{{400, `{"error":"access_denied"}`}},
+			func(err error) bool { return errors.Is(err, ErrAccessDenied) }},
+		{"denied in status field", 60, "", []reply{{200, `{"status":"access_denied"}`}},
+			func(err error) bool { return errors.Is(err, ErrAccessDenied) }},
+		{"expired token", 60, "", []reply{{400, `{"error":"expired_token"}`}},
+			func(err error) bool { return errors.Is(err, ErrLoginExpired) }},
+		{"code lifetime runs out", 1, "", []reply{{400, `{"error":"authorization_pending"}`}},
+			func(err error) bool { return errors.Is(err, ErrLoginExpired) }},
+		{"slow down keeps polling until login_timeout", 60, "1s", []reply{{400, `{"error":"slow_down"}`}},
+			func(err error) bool {
+				var te *api.TimeoutError
+				return errors.As(err, &te) && te.Op == "login"
+			}},
+		{"unknown status", 60, "", []reply{{200, `{"status":"weird"}`}},
+			func(err error) bool { return err != nil && strings.Contains(err.Error(), `"weird"`) }},
+		{"server error", 60, "", []reply{{500, `{"error":"internal","message":"boom"}`}},
+			func(err error) bool {
+				var apiErr *api.APIError
+				return errors.As(err, &apiErr) && apiErr.StatusCode == 500
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, "api_override", deviceServer(t, tt.lifetime, tt.polls...))
+			setConfig(t, "login_timeout", tt.timeout)
+			t.Cleanup(func() { _ = ClearToken() })
+
+			err := Login(context.Background(), LoginOptions{NoOpen: true})
+			if !tt.check(err) {
+				t.Fatalf("Login() = %v", err)
+			}
+			want := ""
+			if err == nil {
+				want = "acc"
+			}
+			if tok, _ := LoadToken(); tok != want {
+				t.Errorf("stored token %q, want %q", tok, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// expirySkew renews access tokens this long before they actually expire so
+// a request never leaves with a token that dies in flight.
+const expirySkew = 30 * time.Second
+
+// Credentials is what a login leaves in the keyring. Older releases stored
+// the bare access token, which parseCredentials still accepts.
+type Credentials struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+func (c Credentials) marshal() ([]byte, error) {
+	if c.RefreshToken == "" && c.ExpiresAt.IsZero() {
+		return []byte(c.AccessToken), nil
+	}
+	return json.Marshal(c)
+}
+
+func parseCredentials(b []byte) (Credentials, error) {
+	b = bytes.TrimSpace(b)
+	if !bytes.HasPrefix(b, []byte("{")) {
+		return Credentials{AccessToken: string(b)}, nil
+	}
+	var c Credentials
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Credentials{}, errors.New("stored credentials are corrupt; run `to login` again")
+	}
+	return c, nil
+}
+
+func (c Credentials) expiring() bool {
+	return !c.ExpiresAt.IsZero() && time.Until(c.ExpiresAt) < expirySkew
+}
+
+// tokenResponse is the token payload of the login poll and refresh
+// endpoints.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func (t tokenResponse) credentials() Credentials {
+	c := Credentials{AccessToken: t.AccessToken, RefreshToken: t.RefreshToken}
+	if t.ExpiresIn > 0 {
+		c.ExpiresAt = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return c
+}
+
+// NewClient returns an API client that authenticates with ActiveToken and
+// renews keyring credentials when they expire or the server rejects them.
+func NewClient() *api.Client {
+	c := api.New(nil)
+	c.SetTokenSource(&tokenChain{})
+	return c
+}
+
+// tokenChain is the api.TokenSource behind NewClient. Tokens from the
+// overrides (--token, TO_TOKEN, ...) are used as given; only keyring
+// credentials can be refreshed.
+type tokenChain struct {
+	mu sync.Mutex
+}
+
+func (s *tokenChain) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cr, src, err := activeCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	if src.Kind == "keyring" && cr.expiring() && cr.RefreshToken != "" {
+		if fresh, err := refresh(ctx, cr); err == nil {
+			setActive(fresh, src)
+			cr = fresh
+		}
+	}
+	return cr.AccessToken, nil
+}
+
+func (s *tokenChain) Refresh(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used, src, err := activeCredentials(ctx)
+	if err != nil {
+		return "", err
+	}
+	if src.Kind != "keyring" {
+		return "", fmt.Errorf("%s was rejected and cannot be refreshed", src)
+	}
+	cr, src, err := locateCredentials(config.Profile(), config.APIBaseURL())
+	if err != nil {
+		return "", err
+	}
+	if cr.AccessToken != used.AccessToken && !cr.expiring() {
+		// Another process rotated the tokens since we read them.
+		setActive(cr, src)
+		return cr.AccessToken, nil
+	}
+	if cr.RefreshToken == "" {
+		return "", errors.New("no refresh token stored")
+	}
+	fresh, err := refresh(ctx, cr)
+	if err != nil {
+		return "", err
+	}
+	setActive(fresh, src)
+	return fresh.AccessToken, nil
+}
+
+// refreshMu serializes refreshes within the process; refresh tokens rotate,
+// so two concurrent refreshes with the same one would fail.
+var refreshMu sync.Mutex
+
+// refresh trades cr's refresh token for new credentials and stores them
+// before returning, so a rotated refresh token is never lost.
+func refresh(ctx context.Context, cr Credentials) (Credentials, error) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	var resp tokenResponse
+	body := map[string]string{"refresh_token": cr.RefreshToken}
+	if err := api.New(nil).DoContext(ctx, "POST", "/cli/token/refresh", body, false, &resp); err != nil {
+		return Credentials{}, err
+	}
+	if resp.AccessToken == "" {
+		return Credentials{}, errors.New("refresh response has no access token")
+	}
+	fresh := resp.credentials()
+	if fresh.RefreshToken == "" {
+		// The server did not rotate it; keep using the old one.
+		fresh.RefreshToken = cr.RefreshToken
+	}
+	if err := saveCredentials(fresh); err != nil {
+		return Credentials{}, err
+	}
+	return fresh, nil
+}
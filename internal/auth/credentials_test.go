@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+func TestCredentialsRoundTrip(t *testing.T) {
+	exp := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		cr   Credentials
+		// stored is what marshal must produce; "" means JSON.
+		stored string
+	}{
+		{"bare access token", Credentials{AccessToken: "pat_abc"}, "pat_abc"},
+		{"with refresh token", Credentials{AccessToken: "a", RefreshToken: "r"}, ""},
+		{"with expiry", Credentials{AccessToken: "a", ExpiresAt: exp}, ""},
+		{"full", Credentials{AccessToken: "a", RefreshToken: "r", ExpiresAt: exp}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.cr.marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.stored != "" && string(b) != tt.stored {
+				t.Errorf("marshal = %q, want %q", b, tt.stored)
+			}
+			if tt.stored == "" && b[0] != '{' {
+				t.Errorf("marshal = %q, want a JSON object", b)
+			}
+			got, err := parseCredentials(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.AccessToken != tt.cr.AccessToken || got.RefreshToken != tt.cr.RefreshToken || !got.ExpiresAt.Equal(tt.cr.ExpiresAt) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.cr)
+			}
+		})
+	}
+}
+
+func TestParseCredentials(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Credentials
+		wantErr bool
+	}{
+		// Releases before refresh tokens stored the bare token, sometimes
+		// with a trailing newline from a plain-text file.
+		{"tok_123", Credentials{AccessToken: "tok_123"}, false},
+		{"tok_123\n", Credentials{AccessToken: "tok_123"}, false},
+		{`{"access_token":"a","refresh_token":"r"}`, Credentials{AccessToken: "a", RefreshToken: "r"}, false},
+		{`  {"access_token":"a"}  `, Credentials{AccessToken: "a"}, false},
+		{`{"access_token":`, Credentials{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCredentials([]byte(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCredentials(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCredentials(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpiring(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  time.Time
+		want bool
+	}{
+		{"no expiry", time.Time{}, false},
+		{"far off", time.Now().Add(time.Hour), false},
+		{"within skew", time.Now().Add(expirySkew / 2), true},
+		{"expired", time.Now().Add(-time.Minute), true},
+	}
+	for _, tt := range tests {
+		if got := (Credentials{AccessToken: "a", ExpiresAt: tt.exp}).expiring(); got != tt.want {
+			t.Errorf("%s: expiring() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTokenKey(t *testing.T) {
+	tests := []struct {
+		profile, apiURL, want string
+	}{
+		// The key used before profiles and per-API scoping, kept so existing
+		// logins carry over.
+		{config.DefaultProfile, config.BuiltinAPIBaseURL, "token"},
+		{config.DefaultProfile, "http://localhost:8787", "token:default@http://localhost:8787"},
+		{"work", config.BuiltinAPIBaseURL, "token:work@" + config.BuiltinAPIBaseURL},
+		{"work", "https://textonly.example.com/api", "token:work@https://textonly.example.com/api"},
+	}
+	for _, tt := range tests {
+		if got := tokenKey(tt.profile, tt.apiURL); got != tt.want {
+			t.Errorf("tokenKey(%q, %q) = %q, want %q", tt.profile, tt.apiURL, got, tt.want)
+		}
+	}
+}
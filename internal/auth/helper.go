@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// Token returns the active access token, refreshing keyring credentials
+// that are about to expire.
+func Token(ctx context.Context) (string, error) {
+	return (&tokenChain{}).Token(ctx)
+}
+
+// Helper implements `to auth helper`, a credential helper speaking git's
+// protocol: key=value lines on stdin up to a blank line, and for "get" the
+// same on stdout. Requests for hosts other than the active API are ignored,
+// as are unknown operations, so the CLI can sit in a chain of helpers.
+func Helper(ctx context.Context, op string, in io.Reader, out io.Writer) error {
+	attrs, err := readCredentialAttrs(in)
+	if err != nil {
+		return err
+	}
+	apiURL := config.APIBaseURL()
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return err
+	}
+	if (attrs["protocol"] != "" && attrs["protocol"] != u.Scheme) || (attrs["host"] != "" && !strings.EqualFold(attrs["host"], u.Host)) {
+		return nil
+	}
+	switch op {
+	case "get":
+		tok, err := Token(ctx)
+		if err != nil {
+			// Nothing to offer; let the next helper answer.
+			return nil
+		}
+		fmt.Fprintf(out, "protocol=%s\nhost=%s\nusername=%s\npassword=%s\n", u.Scheme, u.Host, config.Profile(), tok)
+		if cr, src, err := activeCredentials(ctx); err == nil && src.Kind == "keyring" && !cr.ExpiresAt.IsZero() {
+			fmt.Fprintf(out, "password_expiry_utc=%s\n", strconv.FormatInt(cr.ExpiresAt.Unix(), 10))
+		}
+	case "store":
+		tok := attrs["password"]
+		if tok == "" {
+			return nil
+		}
+		// Tokens from --token, TO_TOKEN and the like are not ours to keep.
+		if _, ok := overrideSource(); ok {
+			return nil
+		}
+		if cur, _, err := ActiveToken(ctx); err == nil && cur == tok {
+			return nil
+		}
+		// Never replace a login that renews itself with a bare token: callers
+		// that store after every use would hand back a stale one once the
+		// access token has rotated.
+		if cr, err := loadCredentials(config.Profile(), apiURL); err == nil && cr.RefreshToken != "" {
+			return nil
+		}
+		return SaveToken(tok)
+	case "erase":
+		cur, err := LoadToken()
+		if err != nil || (attrs["password"] != "" && attrs["password"] != cur) {
+			return nil
+		}
+		return ClearToken()
+	}
+	return nil
+}
+
+func readCredentialAttrs(r io.Reader) (map[string]string, error) {
+	attrs := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if line == "" {
+			break
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid credential line %q: want key=value", line)
+		}
+		attrs[k] = v
+	}
+	return attrs, sc.Err()
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCredentialAttrs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"protocol=https\nhost=textonly.io\n\n", map[string]string{"protocol": "https", "host": "textonly.io"}, false},
+		{"protocol=https\r\nhost=textonly.io\r\n\r\n", map[string]string{"protocol": "https", "host": "textonly.io"}, false},
+		{"password=a=b==\n", map[string]string{"password": "a=b=="}, false},
+		{"host=a\n\nhost=ignored-after-blank-line\n", map[string]string{"host": "a"}, false},
+		{"host=a\nno equals sign\n", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := readCredentialAttrs(strings.NewReader(tt.in))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readCredentialAttrs(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readCredentialAttrs(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHelperGet(t *testing.T) {
+	setConfig(t, "api_override", "https://textonly.example.com/api")
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := saveCredentials(Credentials{AccessToken: "acc", RefreshToken: "ref", ExpiresAt: exp}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ClearToken() })
+
+	answer := "protocol=https\nhost=textonly.example.com\nusername=default\npassword=acc\npassword_expiry_utc=" + strconv.FormatInt(exp.Unix(), 10) + "\n"
+	tests := []struct {
+		name, in, want string
+	}{
+		{"no attributes", "\n", answer},
+		{"matching host", "protocol=https\nhost=textonly.example.com\n\n", answer},
+		{"host in other case", "protocol=https\nhost=TextOnly.Example.com\n\n", answer},
+		{"other host", "protocol=https\nhost=github.com\n\n", ""},
+		{"other protocol", "protocol=http\nhost=textonly.example.com\n\n", ""},
+		{"host with port", "protocol=https\nhost=textonly.example.com:8443\n\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setActive(Credentials{}, Source{})
+			var out bytes.Buffer
+			if err := Helper(context.Background(), "get", strings.NewReader(tt.in), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHelperStoreAndErase(t *testing.T) {
+	setConfig(t, "api_override", "https://textonly.example.com/api")
+	const host = "protocol=https\nhost=textonly.example.com\n"
+	tests := []struct {
+		name     string
+		stored   *Credentials // keyring entry before the call
+		override string       // --token
+		op, in   string
+		want     string // stored access token afterwards
+	}{
+		{"store new token", nil, "", "store", host + "password=new\n\n", "new"},
+		{"store replaces a bare token", &Credentials{AccessToken: "old"}, "", "store", host + "password=new\n\n", "new"},
+		{"store keeps a refreshable login", &Credentials{AccessToken: "old", RefreshToken: "r"}, "", "store", host + "password=new\n\n", "old"},
+		{"store ignores --token", nil, "flagged", "store", host + "password=flagged\n\n", ""},
+		{"store for another host", nil, "", "store", "protocol=https\nhost=github.com\npassword=new\n\n", ""},
+		{"store without password", &Credentials{AccessToken: "old"}, "", "store", host + "\n", "old"},
+		{"erase", &Credentials{AccessToken: "old"}, "", "erase", host + "\n", ""},
+		{"erase with matching password", &Credentials{AccessToken: "old"}, "", "erase", host + "password=old\n\n", ""},
+		{"erase with another password", &Credentials{AccessToken: "old"}, "", "erase", host + "password=stale\n\n", "old"},
+		{"erase for another host", &Credentials{AccessToken: "old"}, "", "erase", "protocol=https\nhost=github.com\n\n", "old"},
+		{"unknown operation", &Credentials{AccessToken: "old"}, "", "frobnicate", host + "\n", "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = ClearToken()
+			t.Cleanup(func() { _ = ClearToken() })
+			if tt.stored != nil {
+				if err := saveCredentials(*tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+			setConfig(t, "token_flag", tt.override)
+			setActive(Credentials{}, Source{})
+			if err := Helper(context.Background(), tt.op, strings.NewReader(tt.in), &bytes.Buffer{}); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := LoadToken(); got != tt.want {
+				t.Errorf("stored token %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelperInvalidInput(t *testing.T) {
+	if err := Helper(context.Background(), "get", strings.NewReader("garbage\n"), &bytes.Buffer{}); err == nil {
+		t.Fatal("want an error for a line without =")
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/keyring"
+	"golang.org/x/term"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+const keyringService = "textonly-cli"
+
+// keyringBackends maps keyring_backend values to 99designs/keyring
+// backends. "file" is the library's encrypted file store.
+var keyringBackends = map[string]keyring.BackendType{
+	"secret-service": keyring.SecretServiceBackend,
+	"kwallet":        keyring.KWalletBackend,
+	"keychain":       keyring.KeychainBackend,
+	"wincred":        keyring.WinCredBackend,
+	"pass":           keyring.PassBackend,
+	"keyctl":         keyring.KeyCtlBackend,
+	"file":           keyring.FileBackend,
+}
+
+var (
+	keyringMu      sync.Mutex
+	openedKeyring  keyring.Keyring
+	openedBackend  keyring.BackendType
+	keyringOpenErr error
+)
+
+// openKeyring opens the configured backend, or with "auto" the first one
+// that answers within config.KeyringTimeout in the library's preference
+// order. The result is kept for the process so the encrypted file store
+// asks for its passphrase at most once.
+func openKeyring() (keyring.Keyring, keyring.BackendType, error) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	if openedKeyring == nil && keyringOpenErr == nil {
+		openedKeyring, openedBackend, keyringOpenErr = probeBackends()
+	}
+	return openedKeyring, openedBackend, keyringOpenErr
+}
+
+func probeBackends() (keyring.Keyring, keyring.BackendType, error) {
+	available := keyring.AvailableBackends()
+	name := config.KeyringBackend()
+	var candidates []keyring.BackendType
+	if name == "auto" {
+		for _, b := range available {
+			// The kernel keyring is emptied on reboot; use it only on request.
+			if b != keyring.KeyCtlBackend {
+				candidates = append(candidates, b)
+			}
+		}
+	} else {
+		b, ok := keyringBackends[name]
+		if !ok {
+			return nil, keyring.InvalidBackend, fmt.Errorf("unknown keyring_backend %q (want auto, %s)", name, strings.Join(backendNames(), ", "))
+		}
+		if !containsBackend(available, b) {
+			return nil, keyring.InvalidBackend, fmt.Errorf("keyring backend %s is not available on %s", name, runtime.GOOS)
+		}
+		candidates = []keyring.BackendType{b}
+	}
+	err := errors.New("no keyring backend available")
+	for _, b := range candidates {
+		var r keyring.Keyring
+		if r, err = probeBackend(b, config.KeyringTimeout()); err == nil {
+			return r, b, nil
+		}
+	}
+	return nil, keyring.InvalidBackend, err
+}
+
+// probeBackend opens b and lists its keys, giving up after timeout; a
+// stalled D-Bus call is left to finish in the background. Opening alone
+// proves little: Secret Service and KWallet only unlock their collection,
+// which is what hangs on a headless session, on first use.
+func probeBackend(b keyring.BackendType, timeout time.Duration) (keyring.Keyring, error) {
+	type result struct {
+		r   keyring.Keyring
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		r, err := keyring.Open(keyringConfig(b))
+		if err == nil {
+			_, err = r.Keys()
+		}
+		done <- result{r, err}
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case res := <-done:
+		return res.r, res.err
+	case <-t.C:
+		return nil, fmt.Errorf("keyring backend %s did not respond within %s", b, timeout)
+	}
+}
+
+func keyringConfig(b keyring.BackendType) keyring.Config {
+	return keyring.Config{
+		AllowedBackends:         []keyring.BackendType{b},
+		ServiceName:             keyringService,
+		LibSecretCollectionName: "login",
+		KWalletAppID:            keyringService,
+		KWalletFolder:           keyringService,
+		KeyCtlScope:             "user",
+		PassPrefix:              keyringService,
+		FileDir:                 keyringFileDir(),
+		FilePasswordFunc:        keyringPassphrase,
+	}
+}
+
+func keyringFileDir() string { return filepath.Join(config.Dir(), "keyring") }
+
+// errNoPassphrase means the encrypted file store cannot be unlocked here.
+var errNoPassphrase = errors.New("the encrypted credential file needs a passphrase: set TO_KEYRING_PASSWORD or choose another keyring_backend")
+
+// keyringPassphrase unlocks the encrypted file store from
+// TO_KEYRING_PASSWORD, or by asking on the controlling terminal. Asking
+// there rather than on stdin keeps `echo "$PAT" | to login --with-token`
+// working in an interactive shell.
+func keyringPassphrase(prompt string) (string, error) {
+	if p := os.Getenv("TO_KEYRING_PASSWORD"); p != "" {
+		return p, nil
+	}
+	tty, err := openTerminal()
+	if err != nil {
+		return "", errNoPassphrase
+	}
+	defer tty.Close()
+	// The console input handle on Windows cannot be written to.
+	var w io.Writer = tty
+	if runtime.GOOS == "windows" {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "%s: ", prompt)
+	b, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(w)
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 0 {
+		return "", errors.New("empty passphrase")
+	}
+	return string(b), nil
+}
+
+// openTerminal opens the controlling terminal, whatever stdin is.
+func openTerminal() (*os.File, error) {
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !term.IsTerminal(int(f.Fd())) {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a terminal", name)
+	}
+	return f, nil
+}
+
+// checkCredentialStore reports up front whether credentials could be
+// saved, so a login fails before the token is used or the user approves a
+// code rather than after.
+func checkCredentialStore() error {
+	_, b, err := openKeyring()
+	if err != nil {
+		return fmt.Errorf("cannot store credentials: %w", err)
+	}
+	if b == keyring.FileBackend && os.Getenv("TO_KEYRING_PASSWORD") == "" {
+		tty, err := openTerminal()
+		if err != nil {
+			return fmt.Errorf("cannot store credentials: %w", errNoPassphrase)
+		}
+		tty.Close()
+	}
+	return nil
+}
+
+func keyringReadError(b keyring.BackendType, err error) error {
+	// The file backend has no typed error for a wrong passphrase.
+	if b == keyring.FileBackend && strings.Contains(err.Error(), "integrity check failed") {
+		return errors.New("wrong passphrase for the encrypted credential file (TO_KEYRING_PASSWORD)")
+	}
+	return fmt.Errorf("reading credentials from %s keyring: %w", b, err)
+}
+
+// backendSource describes b for `to auth status`.
+func backendSource(b keyring.BackendType) string {
+	if b == keyring.FileBackend {
+		return "encrypted file " + keyringFileDir()
+	}
+	return string(b)
+}
+
+func backendNames() []string {
+	var names []string
+	for name := range keyringBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func containsBackend(list []keyring.BackendType, b keyring.BackendType) bool {
+	for _, x := range list {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// tokenCommandTimeout bounds token_command, which may wait on a password
+// manager prompt.
+const tokenCommandTimeout = time.Minute
+
+var (
+	activeMu sync.Mutex
+	active   *activeCredential
+)
+
+type activeCredential struct {
+	cr  Credentials
+	src Source
+}
+
+// ActiveToken returns the token commands authenticate with and where it
+// came from, trying in order: --token, TO_TOKEN, TO_TOKEN_FILE,
+// token_command, then the keyring entry of the active profile. The result is
+// cached for the rest of the process, so token_command runs at most once.
+func ActiveToken(ctx context.Context) (string, Source, error) {
+	cr, src, err := activeCredentials(ctx)
+	return cr.AccessToken, src, err
+}
+
+func activeCredentials(ctx context.Context) (Credentials, Source, error) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if active != nil {
+		return active.cr, active.src, nil
+	}
+	cr, src, err := resolveCredentials(ctx)
+	if err != nil {
+		return Credentials{}, Source{}, err
+	}
+	active = &activeCredential{cr: cr, src: src}
+	return cr, src, nil
+}
+
+// setActive replaces the cached credential after a login or refresh; a zero
+// Source drops it so the next use resolves again.
+func setActive(cr Credentials, src Source) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if src.Kind == "" {
+		active = nil
+		return
+	}
+	active = &activeCredential{cr: cr, src: src}
+}
+
+func resolveCredentials(ctx context.Context) (Credentials, Source, error) {
+	if src, ok := overrideSource(); ok {
+		tok, err := readOverride(ctx, src)
+		if err != nil {
+			return Credentials{}, Source{}, err
+		}
+		return Credentials{AccessToken: tok}, src, nil
+	}
+	return locateCredentials(config.Profile(), config.APIBaseURL())
+}
+
+// overrideSource reports the first configured source that takes precedence
+// over the keyring, if any.
+func overrideSource() (Source, bool) {
+	switch {
+	case config.TokenFlag() != "":
+		return Source{Kind: "flag", Detail: "--token"}, true
+	case os.Getenv("TO_TOKEN") != "":
+		return Source{Kind: "env", Detail: "TO_TOKEN"}, true
+	case config.TokenFile() != "":
+		return Source{Kind: "token_file", Detail: config.TokenFile()}, true
+	case config.TokenCommand() != "":
+		return Source{Kind: "token_command", Detail: config.TokenCommand()}, true
+	}
+	return Source{}, false
+}
+
+func readOverride(ctx context.Context, src Source) (string, error) {
+	var tok string
+	switch src.Kind {
+	case "flag":
+		tok = config.TokenFlag()
+	case "env":
+		tok = os.Getenv("TO_TOKEN")
+	case "token_file":
+		b, err := os.ReadFile(src.Detail)
+		if err != nil {
+			return "", fmt.Errorf("reading TO_TOKEN_FILE: %w", err)
+		}
+		tok = string(b)
+	case "token_command":
+		out, err := runTokenCommand(ctx, src.Detail)
+		if err != nil {
+			return "", err
+		}
+		tok = out
+	}
+	// Only the first line counts, so `pass show` style output with extra
+	// fields after the secret works as is.
+	tok, _, _ = strings.Cut(strings.TrimSpace(tok), "\n")
+	tok = strings.TrimSpace(tok)
+	if tok == "" {
+		return "", fmt.Errorf("%s produced an empty token", src)
+	}
+	return tok, nil
+}
+
+// runTokenCommand runs command through the shell. Its stderr and stdin stay
+// attached so password managers can prompt. It is bounded by
+// tokenCommandTimeout rather than the request timeout, which API clients
+// only start once the token is known.
+func runTokenCommand(parent context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(parent, tokenCommandTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+	// Don't wait on children of the shell that outlive it holding stdout.
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	if perr := parent.Err(); perr != nil {
+		return "", fmt.Errorf("token_command: %w", perr)
+	}
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("token_command did not finish within %s", tokenCommandTimeout)
+	}
+	if err != nil {
+		return "", fmt.Errorf("token_command failed: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverrideSource(t *testing.T) {
+	tests := []struct {
+		name                     string
+		flag, env, file, command string
+		wantKind, wantDetail     string
+	}{
+		{"none", "", "", "", "", "", ""},
+		{"command only", "", "", "", "echo t", "token_command", "echo t"},
+		{"file beats command", "", "", "/tmp/tok", "echo t", "token_file", "/tmp/tok"},
+		{"env beats file", "", "e", "/tmp/tok", "echo t", "env", "TO_TOKEN"},
+		{"flag beats everything", "f", "e", "/tmp/tok", "echo t", "flag", "--token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, "token_flag", tt.flag)
+			t.Setenv("TO_TOKEN", tt.env)
+			setConfig(t, "token_file", tt.file)
+			setConfig(t, "token_command", tt.command)
+			src, ok := overrideSource()
+			if ok != (tt.wantKind != "") || src.Kind != tt.wantKind || src.Detail != tt.wantDetail {
+				t.Errorf("overrideSource() = %+v, %v; want %s (%s)", src, ok, tt.wantKind, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestReadOverride(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	tests := []struct {
+		name    string
+		key     string // config key, or "TO_TOKEN" for the environment
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"flag", "token_flag", " from-flag ", "from-flag", false},
+		{"env", "TO_TOKEN", "from-env", "from-env", false},
+		{"file with newline", "token_file", write("plain", "from-file\n"), "from-file", false},
+		{"file keeps first line", "token_file", write("multi", "secret\nuser: me\nurl: x\n"), "secret", false},
+		{"empty file", "token_file", write("empty", "\n\n"), "", true},
+		{"missing file", "token_file", filepath.Join(dir, "nope"), "", true},
+		{"command", "token_command", "echo from-command", "from-command", false},
+		{"command keeps first line", "token_command", "printf 'secret\\nextra\\n'", "secret", false},
+		{"failing command", "token_command", "exit 3", "", true},
+		{"silent command", "token_command", "true", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key == "token_command" && runtime.GOOS == "windows" {
+				t.Skip("commands are written for sh")
+			}
+			if tt.key == "TO_TOKEN" {
+				t.Setenv("TO_TOKEN", tt.value)
+			} else {
+				setConfig(t, tt.key, tt.value)
+			}
+			src, ok := overrideSource()
+			if !ok {
+				t.Fatal("no override source")
+			}
+			got, err := readOverride(context.Background(), src)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readOverride() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunTokenCommandDeadline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands are written for sh")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := runTokenCommand(ctx, "sleep 2")
+	if !errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), tokenCommandTimeout.String()) {
+		t.Fatalf("got %v, want the caller's deadline", err)
+	}
+}
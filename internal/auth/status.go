@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/config"
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+	"github.com/textonlyio/textonly-cli/pkg/ui"
+)
+
+// Status describes the active credential, as shown by `to auth status`.
+type Status struct {
+	Profile     string     `json:"profile"`
+	API         string     `json:"api"`
+	Source      Source     `json:"source"`
+	Valid       bool       `json:"valid"`
+	User        string     `json:"user,omitempty"`
+	Scopes      []string   `json:"scopes,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Refreshable bool       `json:"refreshable"`
+	Token       string     `json:"token,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// ShowStatus prints where the active credential comes from and what the
+// server says about it. A rejected credential is reported and then returned
+// as the error so scripts can test the exit code.
+func ShowStatus(ctx context.Context, asJSON, showToken bool) error {
+	st := Status{Profile: config.Profile(), API: config.APIBaseURL()}
+	if _, _, err := activeCredentials(ctx); err != nil {
+		return err
+	}
+
+	c := textonly.NewClient(NewClient())
+	me, authErr := c.Users.Me(ctx)
+	var apiErr *api.APIError
+	if authErr != nil && !(errors.As(authErr, &apiErr) && apiErr.IsAuth()) {
+		return authErr
+	}
+	// Re-read: checking validity may have refreshed the stored tokens.
+	cr, src, err := activeCredentials(ctx)
+	if err != nil {
+		return err
+	}
+	st.Source = src
+	st.Refreshable = cr.RefreshToken != ""
+	if !cr.ExpiresAt.IsZero() {
+		st.ExpiresAt = &cr.ExpiresAt
+	}
+	if showToken {
+		st.Token = cr.AccessToken
+	}
+	if authErr != nil {
+		st.Error = authErr.Error()
+	} else {
+		st.Valid, st.User = true, me.Email
+		if t, err := c.Tokens.Current(ctx); err == nil {
+			st.Scopes = t.Scopes
+			if t.ExpiresAt != nil {
+				st.ExpiresAt = t.ExpiresAt
+			}
+		}
+	}
+
+	if asJSON {
+		ui.PrintJSON(st)
+		return authErr
+	}
+	fmt.Println("profile:", st.Profile)
+	fmt.Println("API:", st.API)
+	fmt.Println("source:", st.Source)
+	if st.Valid {
+		fmt.Println("user:", st.User)
+		fmt.Println("valid: yes")
+	} else {
+		fmt.Println("valid: no")
+	}
+	if st.ExpiresAt != nil {
+		fmt.Printf("expires: %s (%s)\n", st.ExpiresAt.Local().Format(time.RFC3339), untilString(*st.ExpiresAt))
+	} else {
+		fmt.Println("expires: never")
+	}
+	if len(st.Scopes) > 0 {
+		fmt.Println("scopes:", strings.Join(st.Scopes, ", "))
+	}
+	if st.Refreshable {
+		fmt.Println("refresh token: yes")
+	}
+	if showToken {
+		fmt.Println("token:", st.Token)
+	}
+	return authErr
+}
+
+func untilString(t time.Time) string {
+	d := time.Until(t).Round(time.Second)
+	if d < 0 {
+		return "expired " + (-d).String() + " ago"
+	}
+	return "in " + d.String()
+}
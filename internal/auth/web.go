@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+	"github.com/textonlyio/textonly-cli/internal/config"
+)
+
+// webClientID identifies the CLI to the authorization endpoint.
+const webClientID = "to-cli"
+
+// errNoBrowser means the loopback flow cannot work here and Login should
+// fall back to the device flow.
+var errNoBrowser = errors.New("no browser available")
+
+// webLogin runs the OAuth authorization-code flow with PKCE (RFC 7636)
+// through a redirect to a temporary listener on the loopback interface
+// (RFC 8252).
+func webLogin(ctx context.Context) error {
+	if !canOpenBrowser() {
+		return errNoBrowser
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNoBrowser, err)
+	}
+	defer ln.Close()
+	redirectURI := fmt.Sprintf("http://%s/callback", ln.Addr())
+
+	verifier, state := randomURLString(32), randomURLString(16)
+	sum := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {webClientID},
+		"redirect_uri":          {redirectURI},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+		"state":                 {state},
+	}
+	authURL := config.APIBaseURL() + "/oauth/authorize?" + q.Encode()
+	if err := openBrowser(authURL); err != nil {
+		return fmt.Errorf("%w: %v", errNoBrowser, err)
+	}
+	fmt.Fprintf(os.Stderr, "Opened %s\nWaiting for you to approve the login in your browser...\n", authURL)
+
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/callback" {
+				http.NotFound(w, r)
+				return
+			}
+			p := r.URL.Query()
+			if p.Get("state") != state {
+				// Not the redirect we are waiting for: a stray hit or another
+				// local process. Reject it and keep waiting.
+				http.Error(w, "Login callback has the wrong state.", http.StatusBadRequest)
+				return
+			}
+			var res result
+			switch {
+			case p.Get("error") == "access_denied":
+				res.err = ErrAccessDenied
+			case p.Get("error") != "":
+				res.err = fmt.Errorf("login failed: %s %s", p.Get("error"), p.Get("error_description"))
+			case p.Get("code") == "":
+				res.err = errors.New("login callback has no code")
+			default:
+				res.code = p.Get("code")
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			if res.err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "Login failed: %v\n", res.err)
+			} else {
+				fmt.Fprintln(w, "Login complete. You can close this tab and return to the terminal.")
+			}
+			select {
+			case done <- res:
+			default:
+			}
+		}),
+	}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	// Without login_timeout, give up after the lifetime of a device code so
+	// a closed tab doesn't leave the CLI waiting forever.
+	wait := config.LoginTimeout()
+	if wait <= 0 {
+		wait = defaultDeviceCodeLifetime
+	}
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	var res result
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout.C:
+		return &api.TimeoutError{Op: "login", After: wait}
+	case res = <-done:
+	}
+	if res.err != nil {
+		return res.err
+	}
+
+	// The token request is form-encoded (RFC 6749 section 4.1.3) and, like
+	// every call under /oauth/, sent once: the code is single use.
+	var tok tokenResponse
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {webClientID},
+		"code":          {res.code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	if err := api.New(nil).DoContext(ctx, "POST", "/oauth/token", form, false, &tok); err != nil {
+		return err
+	}
+	if tok.AccessToken == "" {
+		return errors.New("token response has no access token")
+	}
+	if err := saveCredentials(tok.credentials()); err != nil {
+		return err
+	}
+	if err := rememberProfile(); err != nil {
+		return err
+	}
+	fmt.Println("login successful")
+	return nil
+}
+
+// canOpenBrowser rules out sessions where a browser either cannot start or
+// would run on a different machine than our loopback listener.
+func canOpenBrowser() bool {
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "" {
+		return false
+	}
+	if runtime.GOOS == "linux" || runtime.GOOS == "freebsd" {
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+	return true
+}
+
+func randomURLString(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -3,9 +3,16 @@ package config
 import (
 	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -21,7 +28,10 @@ func Init() error {
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
 
-	viper.SetDefault("api", "https://textonly.io/api")
+	_ = viper.BindEnv("api_override", "TO_API")
+	viper.SetDefault("api", BuiltinAPIBaseURL)
+	viper.SetDefault("retries", 3)
+	viper.SetDefault("max_upload_size", "10MB")
 	viper.SetConfigName(configFileName)
 	viper.SetConfigType(configFileType)
 	viper.AddConfigPath(Dir())
@@ -41,6 +51,80 @@ func Dir() string {
 	return filepath.Join(userConfigRoot(), configDirName)
 }
 
+// CacheDir is the per-user cache directory for the CLI.
+func CacheDir() string {
+	root, err := os.UserCacheDir()
+	if err != nil {
+		home, _ := os.UserHomeDir()
+		root = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(root, configDirName)
+}
+
+// DefaultProfile is used when no profile was chosen; its credentials keep
+// the storage keys from before profiles existed.
+const DefaultProfile = "default"
+
+var profileNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ValidateProfileName rejects names that cannot be used as config keys and
+// keyring/file names. Config keys are case-insensitive, so names are
+// lowercase.
+func ValidateProfileName(name string) error {
+	if !profileNameRE.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// Profile is the active account profile: --profile, TO_PROFILE, the profile
+// chosen with `to auth switch`, or DefaultProfile.
+func Profile() string {
+	if p := strings.TrimSpace(viper.GetString("profile")); p != "" {
+		return p
+	}
+	return DefaultProfile
+}
+
+// Profiles lists the profiles in the config file plus DefaultProfile.
+func Profiles() []string {
+	seen := map[string]bool{DefaultProfile: true}
+	names := []string{DefaultProfile}
+	for name := range viper.GetStringMap("profiles") {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// ProfileAPI is the API base URL stored for a profile, or "".
+func ProfileAPI(name string) string {
+	v := viper.GetString("profiles." + name + ".api")
+	if v == "" { return "" }
+	return NormalizeAPIURL(v)
+}
+
+// SaveProfile records a profile and its API base URL in the config file.
+func SaveProfile(name, api string) error {
+	return Set("profiles."+name+".api", api)
+}
+
+// UseProfile makes name the active profile for future invocations.
+func UseProfile(name string) error { return Set("profile", name) }
+
+// APIOverridden reports whether --api or TO_API was given.
+func APIOverridden() bool { return viper.GetString("api_override") != "" }
+
+// InsecureHTTPAuth allows bearer tokens over plain http to non-loopback
+// hosts (insecure_http_auth / TO_INSECURE_HTTP_AUTH).
+func InsecureHTTPAuth() bool { return viper.GetBool("insecure_http_auth") }
+
+// NoCache reports whether the on-disk HTTP cache is bypassed (--no-cache).
+func NoCache() bool { return viper.GetBool("no_cache") }
+
 func ensureDir() error { return os.MkdirAll(Dir(), 0o755) }
 
 func Path() string {
@@ -48,12 +132,180 @@ func Path() string {
 	return filepath.Join(Dir(), configFileName+"."+configFileType)
 }
 
-func APIBaseURL() string {
+// BuiltinAPIBaseURL is the hosted TextOnly API.
+const BuiltinAPIBaseURL = "https://textonly.io/api"
+
+// DefaultAPIBaseURL is the API used by profiles without their own api: the
+// top-level api key or the built-in default.
+func DefaultAPIBaseURL() string {
 	v := viper.GetString("api")
-	if v == "" { v = "https://textonly.io/api" }
-	return strings.TrimRight(v, "/")
+	if v == "" { v = BuiltinAPIBaseURL }
+	return NormalizeAPIURL(v)
+}
+
+// NormalizeAPIURL canonicalizes an API base URL so one server always maps to
+// the same stored credentials: lowercase scheme and host, no default port,
+// userinfo, query, fragment or trailing slash.
+func NormalizeAPIURL(s string) string {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return strings.TrimRight(s, "/")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	u.User, u.RawQuery, u.Fragment, u.RawPath = nil, "", "", ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	return u.String()
+}
+
+// APIBaseURL resolves the API in order: --api/TO_API, the active profile's
+// api, the top-level api key, the built-in default.
+func APIBaseURL() string {
+	v := viper.GetString("api_override")
+	if v == "" { v = ProfileAPI(Profile()) }
+	if v == "" { return DefaultAPIBaseURL() }
+	return NormalizeAPIURL(v)
+}
+
+// Retries returns how many times an idempotent request is retried after a
+// transient failure.
+func Retries() int {
+	n := viper.GetInt("retries")
+	if n < 0 { n = 0 }
+	return n
+}
+
+// Debug reports whether HTTP traffic should be traced to stderr (--debug,
+// TO_DEBUG=1).
+func Debug() bool { return viper.GetBool("debug") }
+
+// DebugFile is where a HAR capture of HTTP traffic is written, if anywhere.
+func DebugFile() string { return viper.GetString("debug_file") }
+
+// Defaults for the request deadlines; see Timeout and ConnectTimeout.
+const (
+	DefaultTimeout        = 20 * time.Second
+	DefaultConnectTimeout = 10 * time.Second
+)
+
+// Timeout is the overall deadline for a single API request, retries
+// included (timeout).
+func Timeout() time.Duration { return TimeoutOr(DefaultTimeout) }
+
+// TimeoutOr returns the configured timeout, or def if the user set none.
+func TimeoutOr(def time.Duration) time.Duration {
+	if !viper.IsSet("timeout") {
+		return def
+	}
+	return duration("timeout", def)
+}
+
+// ConnectTimeout bounds dialing and the TLS handshake (connect_timeout).
+func ConnectTimeout() time.Duration {
+	return duration("connect_timeout", DefaultConnectTimeout)
 }
 
+// LoginTimeout caps how long login waits for approval (login_timeout); 0
+// means until the device code expires.
+func LoginTimeout() time.Duration { return duration("login_timeout", 0) }
+
+// TokenFlag is the token given with --token.
+func TokenFlag() string { return viper.GetString("token_flag") }
+
+// TokenFile is a file holding the token (token_file / TO_TOKEN_FILE).
+func TokenFile() string { return viper.GetString("token_file") }
+
+// TokenCommand prints the token on stdout (token_command), e.g.
+// `pass show textonly`.
+func TokenCommand() string { return viper.GetString("token_command") }
+
+// DefaultKeyringTimeout bounds probing a keyring backend, which can stall
+// on a D-Bus session that never answers.
+const DefaultKeyringTimeout = 3 * time.Second
+
+// KeyringBackend is where credentials are stored (keyring_backend): auto,
+// or one of secret-service, kwallet, keychain, wincred, pass, keyctl, file.
+func KeyringBackend() string {
+	if v := strings.ToLower(strings.TrimSpace(viper.GetString("keyring_backend"))); v != "" {
+		return v
+	}
+	return "auto"
+}
+
+// KeyringTimeout bounds opening a keyring backend (keyring_timeout).
+func KeyringTimeout() time.Duration { return duration("keyring_timeout", DefaultKeyringTimeout) }
+
+// durationKeys are the settings read with duration.
+var durationKeys = []string{"timeout", "connect_timeout", "login_timeout", "keyring_timeout"}
+
+// ValidateDurations checks every duration setting, so a typo fails the
+// command instead of silently falling back to the default.
+func ValidateDurations() error {
+	for _, key := range durationKeys {
+		v := strings.TrimSpace(viper.GetString(key))
+		if v == "" {
+			continue
+		}
+		if _, err := parseDuration(v); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// duration reads key as a Go duration ("90s", "2m") or a number of seconds.
+// Invalid values are rejected up front by ValidateDurations.
+func duration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(viper.GetString(key))
+	if v == "" {
+		return def
+	}
+	d, err := parseDuration(v)
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+func parseDuration(v string) (time.Duration, error) {
+	var d time.Duration
+	if n, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(n) * time.Second
+	} else if d, err = time.ParseDuration(v); err != nil {
+		return 0, fmt.Errorf("%q is not a duration like 30s or 5m, or a number of seconds", v)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%q is negative", v)
+	}
+	return d, nil
+}
+
+// MaxUploadSize is the largest note content, in bytes, the CLI will send.
+// The config value accepts units, e.g. "10MB".
+func MaxUploadSize() int64 { return int64(viper.GetSizeInBytes("max_upload_size")) }
+
+// UploadGzip reports whether note uploads are gzip-compressed by default.
+func UploadGzip() bool { return viper.GetBool("upload_gzip") }
+
+// TLS settings for self-hosted instances; see api.NewTransport.
+func CAFile() string           { return viper.GetString("ca_file") }
+func ClientCert() string       { return viper.GetString("client_cert") }
+func ClientKey() string        { return viper.GetString("client_key") }
+func TLSMinVersion() string    { return viper.GetString("tls_min_version") }
+func InsecureSkipVerify() bool { return viper.GetBool("insecure_skip_verify") }
+
 func Get(key string) (string, bool) {
 	if !viper.IsSet(key) {
 		return "", false
@@ -62,16 +314,80 @@ func Get(key string) (string, bool) {
 }
 
 func Set(key, value string) error {
-	if err := ensureDir(); err != nil { return err }
+	if err := updateFile(func(m map[string]any) { setPath(m, key, value) }); err != nil { return err }
 	viper.Set(key, value)
-	return viper.WriteConfigAs(Path())
+	return nil
 }
 
 func Unset(key string) error {
 	if !viper.IsSet(key) {
 		return errors.New("key not set")
 	}
-	if err := ensureDir(); err != nil { return err }
+	if err := updateFile(func(m map[string]any) { deletePath(m, key) }); err != nil { return err }
 	viper.Set(key, nil)
-	return viper.WriteConfigAs(Path())
+	return nil
+}
+
+// updateFile edits only what the config file itself contains, so flag, env
+// and default values never get persisted by a set/unset. The new file is
+// written next to the old one and renamed over it, so an interrupted or
+// concurrent write never leaves a truncated config behind.
+func updateFile(edit func(map[string]any)) error {
+	if err := ensureDir(); err != nil { return err }
+	path := Path()
+	in := viper.New()
+	in.SetConfigFile(path)
+	if err := in.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	settings := in.AllSettings()
+	edit(settings)
+	out := viper.New()
+	out.SetConfigType(configFileType)
+	if err := out.MergeConfigMap(settings); err != nil { return err }
+
+	mode := fs.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+configFileName+".*."+configFileType)
+	if err != nil { return err }
+	tmp := f.Name()
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	err = out.WriteConfigAs(tmp)
+	if err == nil { err = os.Chmod(tmp, mode) }
+	if err == nil { err = os.Rename(tmp, path) }
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func setPath(m map[string]any, key, value string) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+func deletePath(m map[string]any, key string) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, parts[len(parts)-1])
 }
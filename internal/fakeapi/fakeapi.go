@@ -0,0 +1,718 @@
+// Package fakeapi is an in-process stand-in for the TextOnly API that
+// implements the endpoints the CLI uses. It backs `to dev fake-server` and
+// is meant for offline demos, script rehearsals and integration tests, not
+// for production use.
+package fakeapi
+
+import (
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+)
+
+type Options struct {
+	// DataDir persists state to DataDir/state.json; empty keeps it in memory.
+	DataDir string
+	// Token, if set, is accepted as an already issued access token.
+	Token string
+	// Email is the address of the single fake user.
+	Email string
+	// ManualApprove requires visiting /activate before a device login
+	// completes; by default the first poll succeeds.
+	ManualApprove bool
+	// RateLimit, if positive, allows that many requests per minute and
+	// reports the budget in X-RateLimit-* headers.
+	RateLimit int
+	// TokenTTL, if positive, makes access tokens issued by login and refresh
+	// expire after that long.
+	TokenTTL time.Duration
+}
+
+type deviceLogin struct {
+	UserCode  string    `json:"user_code"`
+	Approved  bool      `json:"approved"`
+	Denied    bool      `json:"denied"`
+	ExpiresAt time.Time `json:"expires_at"`
+	LastPoll  time.Time `json:"last_poll"`
+}
+
+type authCode struct {
+	Challenge   string    `json:"challenge"`
+	RedirectURI string    `json:"redirect_uri"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// devicePollInterval is the polling interval handed out by login start;
+// polling faster earns a slow_down.
+const devicePollInterval = time.Second
+
+type state struct {
+	NextID      int64                         `json:"next_id"`
+	Notes       []*textonly.Note              `json:"notes"`
+	Stats       map[int64]*textonly.NoteStats `json:"stats"`
+	Tokens      map[string]bool               `json:"tokens"`
+	Expiry      map[string]time.Time          `json:"expiry"`
+	Refresh     map[string]bool               `json:"refresh"`
+	PATs        []*textonly.Token             `json:"pats"`
+	AuthCodes   map[string]*authCode          `json:"auth_codes"`
+	Devices     map[string]*deviceLogin       `json:"devices"`
+	Idempotency map[string]int64              `json:"idempotency"`
+}
+
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu sync.Mutex
+	st state
+
+	windowStart time.Time
+	windowUsed  int
+}
+
+func New(opts Options) (*Server, error) {
+	if opts.Email == "" {
+		opts.Email = "dev@example.com"
+	}
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	s.st = state{
+		NextID:      1,
+		Stats:       map[int64]*textonly.NoteStats{},
+		Tokens:      map[string]bool{},
+		Expiry:      map[string]time.Time{},
+		Refresh:     map[string]bool{},
+		AuthCodes:   map[string]*authCode{},
+		Devices:     map[string]*deviceLogin{},
+		Idempotency: map[string]int64{},
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if opts.Token != "" {
+		s.st.Tokens[opts.Token] = true
+	}
+	s.routes()
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Request-Id", randomString(8))
+	if !s.allow(w) {
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// allow applies the fixed one-minute window from Options.RateLimit.
+func (s *Server) allow(w http.ResponseWriter) bool {
+	if s.opts.RateLimit <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	now := time.Now()
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart, s.windowUsed = now, 0
+	}
+	s.windowUsed++
+	remaining := s.opts.RateLimit - s.windowUsed
+	reset := s.windowStart.Add(time.Minute)
+	s.mu.Unlock()
+
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.opts.RateLimit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(max(remaining, 0)))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	if remaining < 0 {
+		h.Set("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, "rate_limited", "too many requests")
+		return false
+	}
+	return true
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("POST /cli/login/start", s.loginStart)
+	s.mux.HandleFunc("POST /cli/login/poll", s.loginPoll)
+	s.mux.HandleFunc("POST /cli/token/refresh", s.refreshToken)
+	s.mux.HandleFunc("GET /activate", s.activate)
+	s.mux.HandleFunc("GET /oauth/authorize", s.authorize)
+	s.mux.HandleFunc("POST /oauth/token", s.exchangeCode)
+	s.mux.HandleFunc("POST /auth/logout", s.authed(s.logout))
+	s.mux.HandleFunc("GET /me", s.authed(s.me))
+	s.mux.HandleFunc("GET /auth/token", s.authed(s.currentToken))
+	s.mux.HandleFunc("GET /tokens", s.authed(s.listTokens))
+	s.mux.HandleFunc("POST /tokens", s.authed(s.createToken))
+	s.mux.HandleFunc("DELETE /tokens/{id}", s.authed(s.revokeToken))
+	s.mux.HandleFunc("GET /notes", s.authed(s.listNotes))
+	s.mux.HandleFunc("POST /notes", s.authed(s.createNote))
+	s.mux.HandleFunc("GET /notes/{id}", s.authed(s.getNote))
+	s.mux.HandleFunc("PATCH /notes/{id}", s.authed(s.updateNote))
+	s.mux.HandleFunc("DELETE /notes/{id}", s.authed(s.deleteNote))
+	s.mux.HandleFunc("POST /notes/{id}/visibility", s.authed(s.setVisibility))
+	s.mux.HandleFunc("GET /notes/{id}/stats", s.authed(s.noteStats))
+}
+
+func (s *Server) load() error {
+	if s.opts.DataDir == "" {
+		return nil
+	}
+	b, err := os.ReadFile(filepath.Join(s.opts.DataDir, "state.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &s.st)
+}
+
+// save persists state; callers hold s.mu.
+func (s *Server) save() {
+	if s.opts.DataDir == "" {
+		return
+	}
+	b, err := json.MarshalIndent(&s.st, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(s.opts.DataDir, 0o700); err != nil {
+		return
+	}
+	tmp := filepath.Join(s.opts.DataDir, "state.json.tmp")
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return
+	}
+	_ = os.Rename(tmp, filepath.Join(s.opts.DataDir, "state.json"))
+}
+
+func (s *Server) authed(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.mu.Lock()
+		exp, expires := s.st.Expiry[tok]
+		valid := ok && s.st.Tokens[tok] && (!expires || time.Now().Before(exp))
+		s.mu.Unlock()
+		if !valid {
+			writeError(w, http.StatusUnauthorized, "unauthorized", "missing or invalid token")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) loginStart(w http.ResponseWriter, r *http.Request) {
+	device, user := randomString(16), strings.ToUpper(randomString(4))
+	s.mu.Lock()
+	s.st.Devices[device] = &deviceLogin{UserCode: user, ExpiresAt: time.Now().Add(10 * time.Minute)}
+	s.save()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"device_code":               device,
+		"user_code":                 user,
+		"verification_uri":          baseURL(r) + "/activate",
+		"verification_uri_complete": baseURL(r) + "/activate?code=" + user,
+		"interval":                  int(devicePollInterval / time.Second),
+		"expires_in":                600,
+	})
+}
+
+func (s *Server) loginPoll(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DeviceCode string `json:"device_code"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.st.Devices[body.DeviceCode]
+	switch {
+	case d == nil:
+		writeError(w, http.StatusBadRequest, "invalid_grant", "unknown device code")
+		return
+	case time.Now().After(d.ExpiresAt):
+		delete(s.st.Devices, body.DeviceCode)
+		s.save()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "expired_token"})
+		return
+	case d.Denied:
+		delete(s.st.Devices, body.DeviceCode)
+		s.save()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "access_denied"})
+		return
+	case time.Since(d.LastPoll) < devicePollInterval:
+		d.LastPoll = time.Now()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "slow_down"})
+		return
+	case !d.Approved && s.opts.ManualApprove:
+		d.LastPoll = time.Now()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "authorization_pending"})
+		return
+	}
+	resp := s.issueTokens()
+	resp["status"] = "approved"
+	delete(s.st.Devices, body.DeviceCode)
+	s.save()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// refreshToken rotates a refresh token: the old one stops working and a new
+// access/refresh pair is returned.
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.st.Refresh[body.RefreshToken] {
+		writeError(w, http.StatusBadRequest, "invalid_grant", "unknown or used refresh token")
+		return
+	}
+	delete(s.st.Refresh, body.RefreshToken)
+	resp := s.issueTokens()
+	s.save()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// issueTokens mints an access/refresh pair; callers hold s.mu and save.
+func (s *Server) issueTokens() map[string]any {
+	tok, refresh := "fake_"+randomString(20), "fakerefresh_"+randomString(20)
+	s.st.Tokens[tok] = true
+	s.st.Refresh[refresh] = true
+	resp := map[string]any{"access_token": tok, "refresh_token": refresh, "token_type": "Bearer"}
+	if s.opts.TokenTTL > 0 {
+		s.st.Expiry[tok] = time.Now().Add(s.opts.TokenTTL)
+		resp["expires_in"] = int(s.opts.TokenTTL.Seconds())
+	}
+	return resp
+}
+
+// activate approves a pending device login: GET /activate?code=USERCODE, or
+// rejects it with &deny=1.
+func (s *Server) activate(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(r.URL.Query().Get("code"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.st.Devices {
+		if code != "" && d.UserCode == code {
+			if r.URL.Query().Get("deny") != "" {
+				d.Denied = true
+				s.save()
+				fmt.Fprintln(w, "denied")
+				return
+			}
+			d.Approved = true
+			s.save()
+			fmt.Fprintln(w, "approved; return to your terminal")
+			return
+		}
+	}
+	fmt.Fprintln(w, "open /activate?code=<user code> to approve a login")
+}
+
+// authorize approves every PKCE authorization request right away (or denies
+// it with &deny=1) and redirects back to the loopback redirect_uri.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Scheme != "http" || (redirect.Hostname() != "127.0.0.1" && redirect.Hostname() != "localhost") {
+		writeError(w, http.StatusBadRequest, "invalid_request", "redirect_uri must be a loopback http URL")
+		return
+	}
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "expected response_type=code with an S256 code_challenge")
+		return
+	}
+	back := url.Values{"state": {q.Get("state")}}
+	if q.Get("deny") != "" {
+		back.Set("error", "access_denied")
+	} else {
+		code := randomString(16)
+		s.mu.Lock()
+		s.st.AuthCodes[code] = &authCode{Challenge: q.Get("code_challenge"), RedirectURI: redirect.String(), ExpiresAt: time.Now().Add(time.Minute)}
+		s.save()
+		s.mu.Unlock()
+		back.Set("code", code)
+	}
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// exchangeCode redeems an authorization code once, checking the PKCE
+// verifier and redirect_uri against the authorization request.
+func (s *Server) exchangeCode(w http.ResponseWriter, r *http.Request) {
+	// Token requests are form-encoded, as RFC 6749 requires.
+	if err := r.ParseForm(); err != nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		writeError(w, http.StatusBadRequest, "invalid_request", "expected an application/x-www-form-urlencoded body")
+		return
+	}
+	body := struct{ GrantType, Code, RedirectURI, CodeVerifier string }{
+		r.PostForm.Get("grant_type"), r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.st.AuthCodes[body.Code]
+	delete(s.st.AuthCodes, body.Code)
+	sum := sha256.Sum256([]byte(body.CodeVerifier))
+	if body.GrantType != "authorization_code" || c == nil || time.Now().After(c.ExpiresAt) ||
+		c.RedirectURI != body.RedirectURI || base64.RawURLEncoding.EncodeToString(sum[:]) != c.Challenge {
+		s.save()
+		writeError(w, http.StatusBadRequest, "invalid_grant", "invalid authorization code or verifier")
+		return
+	}
+	resp := s.issueTokens()
+	s.save()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	delete(s.st.Tokens, tok)
+	delete(s.st.Expiry, tok)
+	s.save()
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, textonly.User{ID: 1, Email: s.opts.Email, Name: "Dev User"})
+}
+
+// currentToken describes the bearer token of the request; login sessions
+// get every scope.
+func (s *Server) currentToken(w http.ResponseWriter, r *http.Request) {
+	tok := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.st.PATs {
+		if t.Secret == tok {
+			c := *t
+			c.Secret = ""
+			writeJSON(w, http.StatusOK, c)
+			return
+		}
+	}
+	out := textonly.Token{Scopes: []string{"notes:read", "notes:write", "tokens:write"}}
+	if exp, ok := s.st.Expiry[tok]; ok {
+		out.ExpiresAt = &exp
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) listTokens(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	out := []textonly.Token{}
+	for _, t := range s.st.PATs {
+		c := *t
+		c.Secret = ""
+		out = append(out, c)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	var p textonly.CreateTokenParams
+	if !decode(w, r, &p) {
+		return
+	}
+	if p.Name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_request", "name is required")
+		return
+	}
+	if len(p.Scopes) == 0 {
+		p.Scopes = []string{"notes:read", "notes:write"}
+	}
+	now := time.Now().UTC()
+	t := &textonly.Token{ID: "tok_" + randomString(8), Name: p.Name, Scopes: p.Scopes, Secret: "pat_" + randomString(24), CreatedAt: &now, ExpiresAt: p.ExpiresAt}
+	s.mu.Lock()
+	s.st.PATs = append(s.st.PATs, t)
+	s.st.Tokens[t.Secret] = true
+	if t.ExpiresAt != nil {
+		s.st.Expiry[t.Secret] = *t.ExpiresAt
+	}
+	s.save()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) revokeToken(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, t := range s.st.PATs {
+		if t.ID == r.PathValue("id") {
+			delete(s.st.Tokens, t.Secret)
+			delete(s.st.Expiry, t.Secret)
+			s.st.PATs = append(s.st.PATs[:i], s.st.PATs[i+1:]...)
+			s.save()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "not_found", "token not found")
+}
+
+// listNotes returns a plain array, or a cursor-paginated envelope when the
+// client passes limit.
+func (s *Server) listNotes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	notes := []textonly.Note{}
+	for _, n := range s.st.Notes {
+		if (q.Get("public") != "" && !n.Public) || (q.Get("private") != "" && n.Public) {
+			continue
+		}
+		c := *n
+		c.Content = ""
+		notes = append(notes, c)
+	}
+	s.mu.Unlock()
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		writeCachedJSON(w, r, notes)
+		return
+	}
+	start, _ := strconv.Atoi(q.Get("cursor"))
+	if start > len(notes) {
+		start = len(notes)
+	}
+	end := start + limit
+	next := ""
+	if end < len(notes) {
+		next = strconv.Itoa(end)
+	} else {
+		end = len(notes)
+	}
+	writeCachedJSON(w, r, map[string]any{"data": notes[start:end], "next_cursor": next})
+}
+
+func (s *Server) createNote(w http.ResponseWriter, r *http.Request) {
+	var p textonly.CreateNoteParams
+	if !decode(w, r, &p) {
+		return
+	}
+	key := r.Header.Get("Idempotency-Key")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.st.Idempotency[key]; ok && key != "" {
+		if n := s.find(strconv.FormatInt(id, 10)); n != nil {
+			writeJSON(w, http.StatusOK, n)
+			return
+		}
+	}
+	now := time.Now().UTC()
+	n := &textonly.Note{ID: s.st.NextID, GUID: randomString(12), Title: p.Title, Content: p.Content, CreatedAt: &now, UpdatedAt: &now}
+	if p.Public != nil {
+		n.Public = *p.Public
+	}
+	s.st.NextID++
+	s.st.Notes = append(s.st.Notes, n)
+	s.st.Stats[n.ID] = &textonly.NoteStats{}
+	if key != "" {
+		s.st.Idempotency[key] = n.ID
+	}
+	s.save()
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func (s *Server) getNote(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := s.find(r.PathValue("id"))
+	var c textonly.Note
+	if n != nil {
+		c = *n
+		st := s.st.Stats[n.ID]
+		if st == nil {
+			st = &textonly.NoteStats{}
+			s.st.Stats[n.ID] = st
+		}
+		now := time.Now().UTC()
+		st.Views++
+		st.UniqueVisitors = 1
+		st.LastViewedAt = &now
+		s.save()
+	}
+	s.mu.Unlock()
+	if n == nil {
+		writeError(w, http.StatusNotFound, "not_found", "note not found")
+		return
+	}
+	writeCachedJSON(w, r, c)
+}
+
+func (s *Server) updateNote(w http.ResponseWriter, r *http.Request) {
+	var p textonly.UpdateNoteParams
+	if !decode(w, r, &p) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.find(r.PathValue("id"))
+	if n == nil {
+		writeError(w, http.StatusNotFound, "not_found", "note not found")
+		return
+	}
+	if p.Title != nil {
+		n.Title = *p.Title
+	}
+	if p.Content != nil {
+		n.Content = *p.Content
+	}
+	if p.Public != nil {
+		n.Public = *p.Public
+	}
+	now := time.Now().UTC()
+	n.UpdatedAt = &now
+	s.save()
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *Server) deleteNote(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.find(r.PathValue("id"))
+	if n == nil {
+		writeError(w, http.StatusNotFound, "not_found", "note not found")
+		return
+	}
+	for i, m := range s.st.Notes {
+		if m == n {
+			s.st.Notes = append(s.st.Notes[:i], s.st.Notes[i+1:]...)
+			break
+		}
+	}
+	delete(s.st.Stats, n.ID)
+	s.save()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setVisibility(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Visibility textonly.Visibility `json:"visibility"`
+	}
+	if !decode(w, r, &body) {
+		return
+	}
+	if body.Visibility != textonly.VisibilityPublic && body.Visibility != textonly.VisibilityPrivate {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_visibility", "visibility must be public or private")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.find(r.PathValue("id"))
+	if n == nil {
+		writeError(w, http.StatusNotFound, "not_found", "note not found")
+		return
+	}
+	n.Public = body.Visibility == textonly.VisibilityPublic
+	s.save()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) noteStats(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := s.find(r.PathValue("id"))
+	var st textonly.NoteStats
+	if n != nil && s.st.Stats[n.ID] != nil {
+		st = *s.st.Stats[n.ID]
+	}
+	s.mu.Unlock()
+	if n == nil {
+		writeError(w, http.StatusNotFound, "not_found", "note not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// find looks a note up by numeric ID or GUID; callers hold s.mu.
+func (s *Server) find(id string) *textonly.Note {
+	for _, n := range s.st.Notes {
+		if strconv.FormatInt(n.ID, 10) == id || n.GUID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_encoding", err.Error())
+			return false
+		}
+		defer gz.Close()
+		body = gz
+	}
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeCachedJSON serves v with an ETag and honors If-None-Match, so the
+// CLI's response cache can be exercised.
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal", err.Error())
+		return
+	}
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(b, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]string{"error": code, "message": msg})
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func randomString(n int) string {
+	b := make([]byte, (n+1)/2)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)[:n]
+}
@@ -0,0 +1,320 @@
+package fakeapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/fakeapi"
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+)
+
+func newServer(t *testing.T, opts fakeapi.Options) *httptest.Server {
+	t.Helper()
+	s, err := fakeapi.New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// post sends a JSON body and decodes the JSON answer into out.
+func post(t *testing.T, url string, body, out any) int {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type tokens struct {
+	Status       string `json:"status"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+func login(t *testing.T, base string) tokens {
+	t.Helper()
+	var start struct {
+		DeviceCode string `json:"device_code"`
+		UserCode   string `json:"user_code"`
+	}
+	post(t, base+"/cli/login/start", nil, &start)
+	if start.DeviceCode == "" || start.UserCode == "" {
+		t.Fatalf("login start: %+v", start)
+	}
+	var tok tokens
+	post(t, base+"/cli/login/poll", map[string]string{"device_code": start.DeviceCode}, &tok)
+	if tok.Status != "approved" || tok.AccessToken == "" || tok.RefreshToken == "" {
+		t.Fatalf("login poll: %+v", tok)
+	}
+	return tok
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{TokenTTL: time.Hour})
+	ctx := context.Background()
+
+	tok := login(t, ts.URL)
+	if tok.ExpiresIn != 3600 {
+		t.Errorf("expires_in = %d, want 3600", tok.ExpiresIn)
+	}
+	me, err := textonly.New(ts.URL, tok.AccessToken).Users.Me(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me.Email != "dev@example.com" {
+		t.Errorf("email = %q", me.Email)
+	}
+
+	var next tokens
+	if code := post(t, ts.URL+"/cli/token/refresh", map[string]string{"refresh_token": tok.RefreshToken}, &next); code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+	if next.AccessToken == "" || next.RefreshToken == tok.RefreshToken {
+		t.Fatalf("refresh did not rotate: %+v", next)
+	}
+	if _, err := textonly.New(ts.URL, next.AccessToken).Users.Me(ctx); err != nil {
+		t.Fatalf("refreshed token: %v", err)
+	}
+	// Refresh tokens are single use.
+	if code := post(t, ts.URL+"/cli/token/refresh", map[string]string{"refresh_token": tok.RefreshToken}, nil); code != http.StatusBadRequest {
+		t.Errorf("reused refresh token: status %d, want 400", code)
+	}
+}
+
+func TestManualApprove(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{ManualApprove: true})
+	var start struct {
+		DeviceCode string `json:"device_code"`
+		UserCode   string `json:"user_code"`
+	}
+	post(t, ts.URL+"/cli/login/start", nil, &start)
+	var tok tokens
+	post(t, ts.URL+"/cli/login/poll", map[string]string{"device_code": start.DeviceCode}, &tok)
+	if tok.Status != "authorization_pending" {
+		t.Fatalf("before approval: status %q", tok.Status)
+	}
+	resp, err := http.Get(ts.URL + "/activate?code=" + start.UserCode + "&deny=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	post(t, ts.URL+"/cli/login/poll", map[string]string{"device_code": start.DeviceCode}, &tok)
+	if tok.Status != "access_denied" {
+		t.Errorf("after deny: status %q", tok.Status)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{Token: "dev"})
+	_, err := textonly.New(ts.URL, "wrong").Users.Me(context.Background())
+	var apiErr *textonly.APIError
+	if !errors.As(err, &apiErr) || !apiErr.IsAuth() {
+		t.Fatalf("got %v, want an auth APIError", err)
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{Token: "dev"})
+	notes := textonly.New(ts.URL, "dev").Notes
+	ctx := context.Background()
+
+	params := textonly.CreateNoteParams{Title: "once", Content: "x", IdempotencyKey: "job-42"}
+	first, err := notes.Create(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := notes.Create(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("same key created note %d, then %d", first.ID, again.ID)
+	}
+	params.IdempotencyKey = ""
+	other, err := notes.Create(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == first.ID {
+		t.Errorf("fresh key reused note %d", first.ID)
+	}
+	all, err := notes.List(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("got %d notes, want 2", len(all))
+	}
+}
+
+func TestPagination(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{Token: "dev"})
+	ctr := &counter{next: http.DefaultTransport}
+	c := textonly.NewWithOptions(ts.URL, "dev", &textonly.Options{HTTPClient: &http.Client{Transport: ctr}})
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		if _, err := c.Notes.Create(ctx, textonly.CreateNoteParams{Title: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		opts      textonly.ListOptions
+		want      int
+		requests  int
+		truncated bool
+	}{
+		{"plain array", textonly.ListOptions{}, 5, 1, false},
+		{"pages of 2", textonly.ListOptions{PageSize: 2}, 5, 3, false},
+		{"limit", textonly.ListOptions{PageSize: 2, Limit: 3}, 3, 2, true},
+		{"limit equals total", textonly.ListOptions{Limit: 5}, 5, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctr.reset()
+			it := c.Notes.Iter(ctx, &tt.opts)
+			var titles []string
+			for it.Next() {
+				titles = append(titles, it.Note().Title)
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(titles) != tt.want {
+				t.Errorf("got %d notes %v, want %d", len(titles), titles, tt.want)
+			}
+			for i, title := range titles {
+				if title != strconv.Itoa(i+1) {
+					t.Errorf("note %d has title %q", i, title)
+				}
+			}
+			if got := ctr.get(); got != tt.requests {
+				t.Errorf("made %d requests, want %d", got, tt.requests)
+			}
+			if it.Truncated() != tt.truncated {
+				t.Errorf("Truncated() = %v, want %v", it.Truncated(), tt.truncated)
+			}
+		})
+	}
+}
+
+func TestEmptyList(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{Token: "dev"})
+	for path, want := range map[string]string{
+		"/notes":         `[]`,
+		"/notes?limit=2": `{"data":[],"next_cursor":""}`,
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer dev")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(bytes.TrimSpace(b)); got != want {
+			t.Errorf("GET %s = %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	ts := newServer(t, fakeapi.Options{Token: "dev"})
+	ctr := &counter{next: http.DefaultTransport}
+	c := textonly.NewWithOptions(ts.URL, "dev", &textonly.Options{
+		HTTPClient: &http.Client{Transport: ctr},
+		CacheDir:   t.TempDir(),
+	})
+	ctx := context.Background()
+	n, err := c.Notes.Create(ctx, textonly.CreateNoteParams{Title: "cached", Content: "body"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(n.ID, 10)
+
+	if _, err := c.Notes.Get(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	ctr.reset()
+	got, err := c.Notes.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != "body" {
+		t.Errorf("cached note content = %q", got.Content)
+	}
+	if ctr.notModified != 1 {
+		t.Errorf("second GET got %d 304s, want 1", ctr.notModified)
+	}
+
+	// A write drops the cached entry, so the next read sees the change.
+	title := "changed"
+	if err := c.Notes.Update(ctx, id, textonly.UpdateNoteParams{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	ctr.reset()
+	got, err = c.Notes.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != title || ctr.notModified != 0 {
+		t.Errorf("after update: title %q with %d 304s", got.Title, ctr.notModified)
+	}
+}
+
+// counter counts requests and 304 answers passing through it.
+type counter struct {
+	next http.RoundTripper
+
+	mu          sync.Mutex
+	requests    int
+	notModified int
+}
+
+func (c *counter) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if err == nil && resp.StatusCode == http.StatusNotModified {
+		c.notModified++
+	}
+	return resp, err
+}
+
+func (c *counter) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests, c.notModified = 0, 0
+}
+
+func (c *counter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests
+}
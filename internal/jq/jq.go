@@ -0,0 +1,259 @@
+// Package jq implements the small subset of jq used by `to api --jq`:
+// paths (.a.b, .[0], .["k"], .[]), pipes, and the length and keys builtins.
+package jq
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type stage func(v any) ([]any, error)
+
+// Query is a parsed filter, reusable across inputs.
+type Query struct {
+	stages []stage
+}
+
+func Parse(expr string) (*Query, error) {
+	q := &Query{}
+	terms, err := splitPipes(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		term = strings.TrimSpace(term)
+		switch {
+		case term == "length":
+			q.stages = append(q.stages, length)
+		case term == "keys":
+			q.stages = append(q.stages, keys)
+		case strings.HasPrefix(term, "."):
+			steps, err := parsePath(term)
+			if err != nil {
+				return nil, err
+			}
+			q.stages = append(q.stages, steps...)
+		default:
+			return nil, fmt.Errorf("jq: unsupported expression %q", term)
+		}
+	}
+	return q, nil
+}
+
+// splitPipes splits expr at the pipes that are not inside a quoted key.
+func splitPipes(expr string) ([]string, error) {
+	var terms []string
+	start := 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '"':
+			end, err := skipString(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end - 1
+		case '|':
+			terms = append(terms, expr[start:i])
+			start = i + 1
+		}
+	}
+	return append(terms, expr[start:]), nil
+}
+
+// skipString returns the index just past the quoted string starting at
+// s[i], honouring backslash escapes.
+func skipString(s string, i int) (int, error) {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("jq: unterminated string in %q", s)
+}
+
+// Run applies the query to a value decoded from JSON and returns every
+// result it produces.
+func (q *Query) Run(input any) ([]any, error) {
+	values := []any{input}
+	for _, st := range q.stages {
+		var next []any
+		for _, v := range values {
+			out, err := st(v)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, out...)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+func parsePath(term string) ([]stage, error) {
+	var steps []stage
+	i := 0
+	for i < len(term) {
+		switch term[i] {
+		case '.':
+			i++
+			j := i
+			for j < len(term) && isIdent(term[j]) {
+				j++
+			}
+			if j > i {
+				steps = append(steps, field(term[i:j]))
+				i = j
+			}
+		case '[':
+			end := i + 1
+			for end < len(term) && term[end] != ']' {
+				if term[end] == '"' {
+					var err error
+					if end, err = skipString(term, end); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				end++
+			}
+			if end >= len(term) {
+				return nil, fmt.Errorf("jq: unterminated [ in %q", term)
+			}
+			inner := strings.TrimSpace(term[i+1 : end])
+			i = end + 1
+			switch {
+			case inner == "":
+				steps = append(steps, iterate)
+			case strings.HasPrefix(inner, `"`):
+				name, err := strconv.Unquote(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jq: bad key %s", inner)
+				}
+				steps = append(steps, field(name))
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("jq: bad index %s", inner)
+				}
+				steps = append(steps, index(n))
+			}
+		default:
+			return nil, fmt.Errorf("jq: unexpected %q in %q", term[i], term)
+		}
+	}
+	return steps, nil
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func field(name string) stage {
+	return func(v any) ([]any, error) {
+		switch t := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case map[string]any:
+			return []any{t[name]}, nil
+		}
+		return nil, fmt.Errorf("jq: cannot index %s with %q", typeName(v), name)
+	}
+}
+
+func index(n int) stage {
+	return func(v any) ([]any, error) {
+		switch t := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case []any:
+			i := n
+			if i < 0 {
+				i += len(t)
+			}
+			if i < 0 || i >= len(t) {
+				return []any{nil}, nil
+			}
+			return []any{t[i]}, nil
+		}
+		return nil, fmt.Errorf("jq: cannot index %s with number", typeName(v))
+	}
+}
+
+func iterate(v any) ([]any, error) {
+	switch t := v.(type) {
+	case []any:
+		return t, nil
+	case map[string]any:
+		out := make([]any, 0, len(t))
+		for _, k := range sortedKeys(t) {
+			out = append(out, t[k])
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("jq: cannot iterate over %s", typeName(v))
+}
+
+func length(v any) ([]any, error) {
+	switch t := v.(type) {
+	case nil:
+		return []any{0}, nil
+	case string:
+		return []any{len([]rune(t))}, nil
+	case []any:
+		return []any{len(t)}, nil
+	case map[string]any:
+		return []any{len(t)}, nil
+	}
+	return nil, fmt.Errorf("jq: %s has no length", typeName(v))
+}
+
+func keys(v any) ([]any, error) {
+	switch t := v.(type) {
+	case map[string]any:
+		out := []any{}
+		for _, k := range sortedKeys(t) {
+			out = append(out, k)
+		}
+		return []any{out}, nil
+	case []any:
+		out := make([]any, len(t))
+		for i := range t {
+			out[i] = i
+		}
+		return []any{out}, nil
+	}
+	return nil, fmt.Errorf("jq: %s has no keys", typeName(v))
+}
+
+func sortedKeys(m map[string]any) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,117 @@
+package jq
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	const input = `{
+		"data": [{"id": 1, "title": "a"}, {"id": 2, "title": "b"}],
+		"a|b": "piped",
+		"x]y": "bracketed",
+		"q\"k": "quoted",
+		"empty": {},
+		"name": "héllo"
+	}`
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`.`, ``},
+		{`.data[0].id`, `[1]`},
+		{`.data[-1].title`, `["b"]`},
+		{`.data[5]`, `[null]`},
+		{`.data[].id`, `[1, 2]`},
+		{`.data | length`, `[2]`},
+		{`.data[0] | keys`, `[["id", "title"]]`},
+		{`.data | keys`, `[[0, 1]]`},
+		{`.empty | keys`, `[[]]`},
+		{`.name | length`, `[5]`},
+		{`.missing.deeper`, `[null]`},
+		{`.["a|b"]`, `["piped"]`},
+		{`.["a|b"] | length`, `[5]`},
+		{`.["x]y"]`, `["bracketed"]`},
+		{`.["q\"k"]`, `["quoted"]`},
+		{`.empty[]`, `[]`},
+	}
+	var in any
+	if err := json.Unmarshal([]byte(input), &in); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			q, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := q.Run(in)
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if tt.expr == "." {
+				if !reflect.DeepEqual(got, []any{in}) {
+					t.Fatalf("got %v, want the input", got)
+				}
+				return
+			}
+			var want []any
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if gotJSON, wantJSON := mustJSON(t, got), mustJSON(t, want); gotJSON != wantJSON {
+				t.Errorf("got %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		`map(.id)`,
+		`.data[`,
+		`.["unterminated]`,
+		`.["a|b]`,
+		`.[x]`,
+		`.a!`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): want error", expr)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, tt := range []struct{ expr, input string }{
+		{`.a`, `[1]`},
+		{`.[0]`, `{"a": 1}`},
+		{`.[]`, `"s"`},
+		{`length`, `true`},
+		{`keys`, `"s"`},
+	} {
+		var in any
+		if err := json.Unmarshal([]byte(tt.input), &in); err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if _, err := q.Run(in); err == nil {
+			t.Errorf("%s on %s: want error", tt.expr, tt.input)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	if s, ok := v.([]any); ok && s == nil {
+		v = []any{}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
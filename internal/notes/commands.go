@@ -1,6 +1,8 @@
 package notes
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,18 +10,31 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
-	"strconv"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/textonlyio/textonly-cli/internal/api"
 	"github.com/textonlyio/textonly-cli/internal/auth"
+	"github.com/textonlyio/textonly-cli/internal/config"
+	"github.com/textonlyio/textonly-cli/pkg/textonly"
+	"github.com/textonlyio/textonly-cli/pkg/ui"
 )
 
-func SetVisibility(id string, visibility string) error {
-	v := map[string]any{"visibility": visibility}
-	client := api.New(auth.LoadToken)
-	return client.Do("POST", "/notes/"+id+"/visibility", v, true, nil)
+func newClient() *textonly.Client { return textonly.NewClient(auth.NewClient()) }
+
+// uploadTimeout replaces the default request timeout for content uploads,
+// which can legitimately take minutes; an explicit --timeout still wins.
+const uploadTimeout = 10 * time.Minute
+
+func newUploadClient() *textonly.Client {
+	c := auth.NewClient()
+	c.SetTimeout(config.TimeoutOr(uploadTimeout))
+	return textonly.NewClient(c)
+}
+
+func SetVisibility(ctx context.Context, id string, visibility string) error {
+	return newClient().Notes.SetVisibility(ctx, id, textonly.Visibility(visibility))
 }
 
 func NewListCommand() *cobra.Command {
@@ -27,33 +42,44 @@ func NewListCommand() *cobra.Command {
 		pub bool
 		priv bool
 		asJSON bool
+		all bool
+		limit int
+		pageSize int
 	)
 	c := &cobra.Command{
 		Use:   "list",
 		Short: "List notes",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if pub && priv { return errors.New("cannot set both --public and --private") }
-			client := api.New(auth.LoadToken)
-			qs := ""
-			if pub { qs += "?public=1" }
-			if priv { if qs == "" { qs += "?private=1" } else { qs += "&private=1" } }
-			path := "/notes" + qs
-			if asJSON {
-				var v []map[string]any
-				if err := client.Do("GET", path, nil, true, &v); err != nil { return err }
-				b, _ := json.MarshalIndent(v, "", "  ")
-				fmt.Println(string(b))
-				return nil
+			if limit < 0 || pageSize < 0 { return errors.New("--limit and --page-size must not be negative") }
+			opts := &textonly.ListOptions{PageSize: pageSize, Limit: limit}
+			if all { opts.Limit = 0 }
+			if pub { opts.Visibility = textonly.VisibilityPublic }
+			if priv { opts.Visibility = textonly.VisibilityPrivate }
+			it := newClient().Notes.Iter(cmd.Context(), opts)
+			var out *ui.JSONArray
+			if asJSON { out = ui.NewJSONArray(os.Stdout) }
+			for it.Next() {
+				n := it.Note()
+				if asJSON {
+					if err := out.Write(rawOr(n.Raw, n)); err != nil { return err }
+					continue
+				}
+				fmt.Printf("%d\t%s\n", n.ID, n.Title)
 			}
-			var v []struct{ ID int `json:"id"`; Title string `json:"title"` }
-			if err := client.Do("GET", path, nil, true, &v); err != nil { return err }
-			for _, n := range v { fmt.Printf("%d\t%s\n", n.ID, n.Title) }
-			return nil
+			if asJSON { _ = out.Close() }
+			if it.Truncated() {
+				fmt.Fprintf(os.Stderr, "showing the first %d notes; use --limit N or --all to see more\n", opts.Limit)
+			}
+			return it.Err()
 		},
 	}
 	c.Flags().BoolVar(&pub, "public", false, "Show only public notes")
 	c.Flags().BoolVar(&priv, "private", false, "Show only private notes")
 	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	c.Flags().IntVar(&limit, "limit", 30, "Maximum number of notes to list")
+	c.Flags().IntVar(&pageSize, "page-size", 0, "Notes to fetch per request (default: server decides)")
+	c.Flags().BoolVar(&all, "all", false, "List all notes, ignoring --limit")
 	return c
 }
 
@@ -64,25 +90,21 @@ func NewViewCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Short: "View a note",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := api.New(auth.LoadToken)
-			var v map[string]any
-			if err := client.Do("GET", "/notes/"+args[0], nil, true, &v); err != nil { return err }
-			if asJSON { b,_ := json.MarshalIndent(v, "", "  "); fmt.Println(string(b)); return nil }
-			content, _ := v["content"].(string)
-			title, _ := v["title"].(string)
+			n, err := newClient().Notes.Get(cmd.Context(), args[0])
+			if err != nil { return err }
+			if asJSON { ui.PrintJSON(rawOr(n.Raw, n)); return nil }
 			if raw {
-				fmt.Println(content)
+				fmt.Println(n.Content)
 				return nil
 			}
 			// Default: title + content
-			fmt.Println(title)
-			if content != "" {
+			fmt.Println(n.Title)
+			if n.Content != "" {
 				fmt.Println()
-				fmt.Println(content)
+				fmt.Println(n.Content)
 			}
 			if openFlag {
-				if guid, ok := v["guid"].(string); ok && guid != "" {
-					url := "https://textonly.io/n/" + guid
+				if url := n.PublicURL(); url != "" {
 					_ = openURL(url)
 				}
 			}
@@ -96,23 +118,23 @@ func NewViewCommand() *cobra.Command {
 }
 
 func NewCreateCommand() *cobra.Command {
-	var title, file string
-	var stdin, asJSON bool
+	var title, file, idemKey string
+	var stdin, asJSON, gzip bool
 	var pub, priv bool
 	c := &cobra.Command{
 		Use:   "create",
 		Short: "Create a note",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if pub && priv { return errors.New("cannot set both --public and --private") }
-			content, err := readContent(file, stdin)
+			content, err := openContent(file, stdin)
 			if err != nil { return err }
-			payload := map[string]any{"title": title, "content": content}
-			if pub { payload["public"] = true } else if priv { payload["public"] = false }
-			client := api.New(auth.LoadToken)
-			var out map[string]any
-			if err := client.Do("POST", "/notes", payload, true, &out); err != nil { return err }
-			if asJSON { b,_ := json.MarshalIndent(out, "", "  "); fmt.Println(string(b)); return nil }
-			if id, ok := out["id"].(float64); ok { fmt.Println(strconv.Itoa(int(id))) } else { fmt.Println("created") }
+			defer content.Close()
+			params := textonly.CreateNoteParams{Title: title, Public: visibilityFlag(pub, priv), IdempotencyKey: idemKey}
+			n, err := newUploadClient().Notes.CreateFromReader(cmd.Context(), params, content, content.uploadOptions(useGzip(cmd, gzip)))
+			content.done()
+			if err != nil { return err }
+			if asJSON { ui.PrintJSON(rawOr(n.Raw, n)); return nil }
+			if n.ID != 0 { fmt.Println(n.ID) } else { fmt.Println("created") }
 			return nil
 		},
 	}
@@ -122,12 +144,14 @@ func NewCreateCommand() *cobra.Command {
 	c.Flags().BoolVar(&pub, "public", false, "Set visibility to public")
 	c.Flags().BoolVar(&priv, "private", false, "Set visibility to private")
 	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
+	c.Flags().StringVar(&idemKey, "idempotency-key", "", "Reuse this key so reruns never create a duplicate note")
+	c.Flags().BoolVar(&gzip, "gzip", false, "Gzip-compress the upload (upload_gzip)")
 	return c
 }
 
 func NewUpdateCommand() *cobra.Command {
 	var title, file string
-	var stdin bool
+	var stdin, gzip bool
 	var pub, priv bool
 	c := &cobra.Command{
 		Use:   "update <id>",
@@ -135,12 +159,15 @@ func NewUpdateCommand() *cobra.Command {
 		Short: "Update a note",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if pub && priv { return errors.New("cannot set both --public and --private") }
-			payload := map[string]any{}
-			if title != "" { payload["title"] = title }
-			if file != "" || stdin { c, err := readContent(file, stdin); if err != nil { return err }; payload["content"] = c }
-			if pub { payload["public"] = true } else if priv { payload["public"] = false }
-			client := api.New(auth.LoadToken)
-			return client.Do("PATCH", "/notes/"+args[0], payload, true, nil)
+			params := textonly.UpdateNoteParams{Public: visibilityFlag(pub, priv)}
+			if title != "" { params.Title = &title }
+			if file == "" && !stdin { return newClient().Notes.Update(cmd.Context(), args[0], params) }
+			content, err := openContent(file, stdin)
+			if err != nil { return err }
+			defer content.Close()
+			err = newUploadClient().Notes.UpdateFromReader(cmd.Context(), args[0], params, content, content.uploadOptions(useGzip(cmd, gzip)))
+			content.done()
+			return err
 		},
 	}
 	c.Flags().StringVar(&title, "title", "", "New title")
@@ -148,6 +175,7 @@ func NewUpdateCommand() *cobra.Command {
 	c.Flags().BoolVar(&stdin, "stdin", false, "Read content from stdin")
 	c.Flags().BoolVar(&pub, "public", false, "Set visibility to public")
 	c.Flags().BoolVar(&priv, "private", false, "Set visibility to private")
+	c.Flags().BoolVar(&gzip, "gzip", false, "Gzip-compress the upload (upload_gzip)")
 	return c
 }
 
@@ -159,8 +187,7 @@ func NewDeleteCommand() *cobra.Command {
 		Short: "Delete a note",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if !yes { return errors.New("use --yes to confirm") }
-			client := api.New(auth.LoadToken)
-			return client.Do("DELETE", "/notes/"+args[0], nil, true, nil)
+			return newClient().Notes.Delete(cmd.Context(), args[0])
 		},
 	}
 	c.Flags().BoolVar(&yes, "yes", false, "Confirm deletion")
@@ -175,10 +202,9 @@ func NewVisibilityCommand() *cobra.Command {
 		Short: "Change note visibility",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if pub == priv { return errors.New("must set exactly one of --public or --private") }
-			v := map[string]any{}
-			if pub { v["visibility"] = "public" } else { v["visibility"] = "private" }
-			client := api.New(auth.LoadToken)
-			return client.Do("POST", "/notes/"+args[0]+"/visibility", v, true, nil)
+			v := textonly.VisibilityPrivate
+			if pub { v = textonly.VisibilityPublic }
+			return newClient().Notes.SetVisibility(cmd.Context(), args[0], v)
 		},
 	}
 	c.Flags().BoolVar(&pub, "public", false, "Set visibility to public")
@@ -193,12 +219,10 @@ func NewStatsCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Short: "Show note stats",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := api.New(auth.LoadToken)
-			var v map[string]any
-			if err := client.Do("GET", "/notes/"+args[0]+"/stats", nil, true, &v); err != nil { return err }
-			if asJSON { b,_ := json.MarshalIndent(v, "", "  "); fmt.Println(string(b)); return nil }
-			for k, vv := range v { fmt.Printf("%s: %v\n", k, vv) }
-			return nil
+			st, err := newClient().Notes.Stats(cmd.Context(), args[0])
+			if err != nil { return err }
+			if asJSON { ui.PrintJSON(rawOr(st.Raw, st)); return nil }
+			return printFields(rawOr(st.Raw, st))
 		},
 	}
 	c.Flags().BoolVar(&asJSON, "json", false, "Output JSON")
@@ -211,28 +235,105 @@ func NewLinkCommand() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Short: "Print share/public URL if enabled",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := api.New(auth.LoadToken)
-			var v map[string]any
-			if err := client.Do("GET", "/notes/"+args[0], nil, true, &v); err != nil { return err }
-			if guid, ok := v["guid"].(string); ok && guid != "" { fmt.Println("https://textonly.io/n/"+guid); return nil }
+			n, err := newClient().Notes.Get(cmd.Context(), args[0])
+			if err != nil { return err }
+			if url := n.PublicURL(); url != "" { fmt.Println(url); return nil }
 			return errors.New("note is not public or has no link")
 		},
 	}
 	return c
 }
 
-func readContent(file string, stdin bool) (string, error) {
+// rawOr returns what the API sent, so --json keeps fields the SDK types
+// don't declare, falling back to v when there is no body.
+func rawOr(raw json.RawMessage, v any) any {
+	if len(raw) > 0 { return raw }
+	return v
+}
+
+// printFields prints the top-level fields of v as sorted "key: value" lines.
+func printFields(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil { return err }
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var m map[string]any
+	if err := d.Decode(&m); err != nil { return err }
+	keys := make([]string, 0, len(m))
+	for k := range m { keys = append(keys, k) }
+	sort.Strings(keys)
+	for _, k := range keys { fmt.Printf("%s: %v\n", k, m[k]) }
+	return nil
+}
+
+// visibilityFlag turns --public/--private into the optional public field.
+func visibilityFlag(pub, priv bool) *bool {
+	switch {
+	case pub:
+		v := true
+		return &v
+	case priv:
+		v := false
+		return &v
+	}
+	return nil
+}
+
+// progressThreshold is the content size above which uploads show progress.
+const progressThreshold = 1 << 20
+
+// content is note content ready for a streamed upload. Its size is known and
+// checked against max_upload_size before any request is made.
+type content struct {
+	*os.File
+	size     int64
+	progress *ui.Progress
+}
+
+// openContent opens --file, or spools stdin to a temp file, so the size limit
+// is enforced up front and retries can rewind the body.
+func openContent(file string, stdin bool) (*content, error) {
+	max := config.MaxUploadSize()
 	if stdin {
-		b, err := io.ReadAll(os.Stdin)
-		if err != nil { return "", err }
-		return string(b), nil
+		f, err := os.CreateTemp("", "to-upload-*")
+		if err != nil { return nil, err }
+		_ = os.Remove(f.Name())
+		src := io.Reader(os.Stdin)
+		if max > 0 { src = io.LimitReader(os.Stdin, max+1) }
+		n, err := io.Copy(f, src)
+		if err == nil && max > 0 && n > max { err = fmt.Errorf("content exceeds max_upload_size (%d bytes)", max) }
+		if err == nil { _, err = f.Seek(0, io.SeekStart) }
+		if err != nil { f.Close(); return nil, err }
+		return &content{File: f, size: n}, nil
 	}
 	if file != "" {
-		b, err := os.ReadFile(file)
-		if err != nil { return "", err }
-		return string(b), nil
+		f, err := os.Open(file)
+		if err != nil { return nil, err }
+		fi, err := f.Stat()
+		if err != nil { f.Close(); return nil, err }
+		if max > 0 && fi.Size() > max {
+			f.Close()
+			return nil, fmt.Errorf("%s is %d bytes, above max_upload_size (%d bytes)", file, fi.Size(), max)
+		}
+		return &content{File: f, size: fi.Size()}, nil
+	}
+	return nil, errors.New("provide --file or --stdin")
+}
+
+func (c *content) uploadOptions(gzip bool) *textonly.UploadOptions {
+	opts := &textonly.UploadOptions{Gzip: gzip}
+	if c.size >= progressThreshold {
+		c.progress = ui.NewProgress("uploading", c.size)
+		opts.Progress = c.progress.Update
 	}
-	return "", errors.New("provide --file or --stdin")
+	return opts
+}
+
+func (c *content) done() { c.progress.Done() }
+
+func useGzip(cmd *cobra.Command, flag bool) bool {
+	if cmd.Flags().Changed("gzip") { return flag }
+	return config.UploadGzip()
 }
 
 var openURL = func(u string) error {
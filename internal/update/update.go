@@ -2,14 +2,26 @@ package update
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 
 	selfupdate "github.com/rhysd/go-github-selfupdate/selfupdate"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
 )
 
 const repo = "BT-Pro-Solutions/textonly-cli"
 
 func CheckAndApply(checkOnly bool, currentVersion string) (string, bool, error) {
+	// selfupdate talks to GitHub through the default transport; route it
+	// through ours so the proxy and extra CA roots apply to updates as well.
+	// The API's client certificate and insecure_skip_verify deliberately
+	// don't: a new executable is only ever fetched over verified TLS.
+	transport, err := api.NewPublicTransport()
+	if err != nil {
+		return "", false, err
+	}
+	http.DefaultTransport = transport
 	rel, found, err := selfupdate.DetectLatest(repo)
 	if err != nil {
 		return "", false, err
@@ -0,0 +1,94 @@
+// Package textonly is a typed Go client for the TextOnly API. It is the same
+// client the `to` CLI is built on.
+package textonly
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+)
+
+// Doer performs a single JSON API call. *api.Client implements it; tests and
+// embedders can supply their own.
+type Doer interface {
+	DoContext(ctx context.Context, method, path string, body any, requireAuth bool, out any) error
+}
+
+type Client struct {
+	Notes  *NotesService
+	Tokens *TokensService
+	Users  *UsersService
+}
+
+// NewClient wraps an existing Doer.
+func NewClient(d Doer) *Client {
+	return &Client{
+		Notes:  &NotesService{d: d},
+		Tokens: &TokensService{d: d},
+		Users:  &UsersService{d: d},
+	}
+}
+
+// Defaults used by New and for zero Options fields.
+const (
+	DefaultRetries = 3
+	DefaultTimeout = 20 * time.Second
+)
+
+// Options tunes NewWithOptions. The client never reads the `to` CLI's
+// configuration or writes to its cache; everything it does is set here.
+type Options struct {
+	// HTTPClient sends the requests; nil uses http.DefaultTransport. Set
+	// proxies, TLS and connect timeouts on its transport.
+	HTTPClient *http.Client
+	// Retries is how many times idempotent requests are retried on
+	// connection errors, 429 and 502/503/504; 0 means DefaultRetries and a
+	// negative value disables retries.
+	Retries int
+	// Timeout bounds each call, retries included; 0 means DefaultTimeout and
+	// a negative value disables it.
+	Timeout time.Duration
+	// CacheDir, if set, keeps GET responses there and revalidates them with
+	// ETag/Last-Modified. Caching is off by default.
+	CacheDir string
+	// InsecureHTTPAuth allows sending token over plain http to hosts other
+	// than localhost.
+	InsecureHTTPAuth bool
+}
+
+// New returns a client for the API at baseURL (e.g. https://textonly.io/api)
+// that authenticates with token, using the default Options.
+func New(baseURL, token string) *Client {
+	return NewWithOptions(baseURL, token, nil)
+}
+
+// NewWithOptions is New tuned by opts, which may be nil.
+func NewWithOptions(baseURL, token string, opts *Options) *Client {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	switch {
+	case o.Retries == 0:
+		o.Retries = DefaultRetries
+	case o.Retries < 0:
+		o.Retries = 0
+	}
+	switch {
+	case o.Timeout == 0:
+		o.Timeout = DefaultTimeout
+	case o.Timeout < 0:
+		o.Timeout = 0
+	}
+	return NewClient(api.NewWithOptions(api.Options{
+		BaseURL:          baseURL,
+		TokenProvider:    func() (string, error) { return token, nil },
+		HTTPClient:       o.HTTPClient,
+		Retries:          o.Retries,
+		Timeout:          o.Timeout,
+		CacheDir:         o.CacheDir,
+		InsecureHTTPAuth: o.InsecureHTTPAuth,
+	}))
+}
@@ -0,0 +1,19 @@
+package textonly
+
+import "github.com/textonlyio/textonly-cli/internal/api"
+
+// Errors returned by the client. Use errors.As to tell them apart:
+//
+//	var apiErr *textonly.APIError
+//	if errors.As(err, &apiErr) && apiErr.IsNotFound() { ... }
+type (
+	// APIError is a non-2xx response; its Is* methods classify it.
+	APIError = api.APIError
+	// TimeoutError reports that a call ran past Options.Timeout.
+	TimeoutError = api.TimeoutError
+	// NetworkError wraps a failure to reach the API at all.
+	NetworkError = api.NetworkError
+	// RateLimitError reports a request held back because the rate-limit
+	// budget resets only after the timeout.
+	RateLimitError = api.RateLimitError
+)
@@ -0,0 +1,300 @@
+package textonly
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/textonlyio/textonly-cli/internal/api"
+)
+
+// PublicBaseURL is where public notes are served, keyed by GUID.
+const PublicBaseURL = "https://textonly.io/n/"
+
+type Visibility string
+
+const (
+	VisibilityPublic  Visibility = "public"
+	VisibilityPrivate Visibility = "private"
+)
+
+type Note struct {
+	ID        int64      `json:"id"`
+	GUID      string     `json:"guid,omitempty"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content,omitempty"`
+	Public    bool       `json:"public"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Raw is the note exactly as the API sent it, including fields this
+	// package doesn't know about.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (n *Note) UnmarshalJSON(b []byte) error {
+	type note Note
+	if err := json.Unmarshal(b, (*note)(n)); err != nil {
+		return err
+	}
+	n.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// PublicURL returns the share URL of a public note, or "" if it has none.
+func (n *Note) PublicURL() string {
+	if n.GUID == "" {
+		return ""
+	}
+	return PublicBaseURL + n.GUID
+}
+
+type NoteStats struct {
+	Views          int64      `json:"views"`
+	UniqueVisitors int64      `json:"unique_visitors"`
+	LastViewedAt   *time.Time `json:"last_viewed_at,omitempty"`
+	// Raw is the stats object exactly as the API sent it.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (s *NoteStats) UnmarshalJSON(b []byte) error {
+	type noteStats NoteStats
+	if err := json.Unmarshal(b, (*noteStats)(s)); err != nil {
+		return err
+	}
+	s.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+type ListOptions struct {
+	// Visibility restricts results to public or private notes; empty lists all.
+	Visibility Visibility
+	// PageSize is how many notes to request per page; 0 lets the server decide.
+	PageSize int
+	// Limit stops iteration after this many notes; 0 means no limit.
+	Limit int
+}
+
+type CreateNoteParams struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Public  *bool  `json:"public,omitempty"`
+	// IdempotencyKey deduplicates retried creates server-side; a random key
+	// is used when empty.
+	IdempotencyKey string `json:"-"`
+}
+
+// UpdateNoteParams holds the fields to change; nil fields are left as is.
+type UpdateNoteParams struct {
+	Title   *string `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+	Public  *bool   `json:"public,omitempty"`
+}
+
+type NotesService struct {
+	d Doer
+}
+
+// List returns every note matching opts, following pagination. Use Iter to
+// process large accounts without holding all notes in memory.
+func (s *NotesService) List(ctx context.Context, opts *ListOptions) ([]Note, error) {
+	var notes []Note
+	it := s.Iter(ctx, opts)
+	for it.Next() {
+		notes = append(notes, it.Note())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// Iter returns an iterator that fetches pages lazily as it advances.
+func (s *NotesService) Iter(ctx context.Context, opts *ListOptions) *NoteIterator {
+	it := &NoteIterator{ctx: ctx, d: s.d, more: true}
+	if opts != nil {
+		it.opts = *opts
+	}
+	return it
+}
+
+// Get fetches a note by ID or slug.
+func (s *NotesService) Get(ctx context.Context, id string) (*Note, error) {
+	var n Note
+	if err := s.d.DoContext(ctx, "GET", notePath(id), nil, true, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (s *NotesService) Create(ctx context.Context, params CreateNoteParams) (*Note, error) {
+	var n Note
+	ctx = api.WithIdempotencyKey(ctx, params.IdempotencyKey)
+	if err := s.d.DoContext(ctx, "POST", "/notes", params, true, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// UploadOptions tunes CreateFromReader and UpdateFromReader.
+type UploadOptions struct {
+	// Gzip compresses the request body.
+	Gzip bool
+	// Progress is called with the number of content bytes sent so far.
+	Progress func(sent int64)
+}
+
+// CreateFromReader is Create for large content: the note body is streamed
+// from content instead of being buffered. params.Content is ignored.
+func (s *NotesService) CreateFromReader(ctx context.Context, params CreateNoteParams, content io.Reader, opts *UploadOptions) (*Note, error) {
+	var n Note
+	ctx = api.WithIdempotencyKey(ctx, params.IdempotencyKey)
+	if err := s.d.DoContext(ctx, "POST", "/notes", streamBody(params, content, opts), true, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// UpdateFromReader is Update with the new content streamed from content.
+// params.Content is ignored.
+func (s *NotesService) UpdateFromReader(ctx context.Context, id string, params UpdateNoteParams, content io.Reader, opts *UploadOptions) error {
+	return s.d.DoContext(ctx, "PATCH", notePath(id), streamBody(params, content, opts), true, nil)
+}
+
+func streamBody(fields any, content io.Reader, opts *UploadOptions) *api.StreamBody {
+	sb := &api.StreamBody{Fields: fields, Field: "content", Content: content}
+	if opts != nil {
+		sb.Gzip, sb.Progress = opts.Gzip, opts.Progress
+	}
+	return sb
+}
+
+func (s *NotesService) Update(ctx context.Context, id string, params UpdateNoteParams) error {
+	return s.d.DoContext(ctx, "PATCH", notePath(id), params, true, nil)
+}
+
+func (s *NotesService) Delete(ctx context.Context, id string) error {
+	return s.d.DoContext(ctx, "DELETE", notePath(id), nil, true, nil)
+}
+
+func (s *NotesService) SetVisibility(ctx context.Context, id string, v Visibility) error {
+	body := map[string]Visibility{"visibility": v}
+	return s.d.DoContext(ctx, "POST", notePath(id)+"/visibility", body, true, nil)
+}
+
+func (s *NotesService) Stats(ctx context.Context, id string) (*NoteStats, error) {
+	var st NoteStats
+	if err := s.d.DoContext(ctx, "GET", notePath(id)+"/stats", nil, true, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func notePath(id string) string { return "/notes/" + url.PathEscape(id) }
+
+// NoteIterator walks a paginated note listing:
+//
+//	it := c.Notes.Iter(ctx, nil)
+//	for it.Next() {
+//		n := it.Note()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// The API answers GET /notes either with a plain array, which is the only
+// page, or with an envelope {"data": [...], "next_cursor": "..."} (or
+// "next_page" for page-numbered listings). Iteration ends when neither is
+// set.
+type NoteIterator struct {
+	ctx  context.Context
+	d    Doer
+	opts ListOptions
+
+	page    []Note
+	idx     int
+	seen    int
+	cursor  string
+	pageNum int
+	more    bool
+	err     error
+	current Note
+}
+
+func (it *NoteIterator) Next() bool {
+	if it.err != nil || (it.opts.Limit > 0 && it.seen >= it.opts.Limit) {
+		return false
+	}
+	for it.idx >= len(it.page) {
+		if !it.more {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+	}
+	it.current = it.page[it.idx]
+	it.idx++
+	it.seen++
+	return true
+}
+
+// Note returns the note Next advanced to.
+func (it *NoteIterator) Note() Note { return it.current }
+
+// Truncated reports whether iteration stopped at ListOptions.Limit with
+// more notes left on the server.
+func (it *NoteIterator) Truncated() bool {
+	return it.err == nil && it.opts.Limit > 0 && it.seen >= it.opts.Limit && (it.idx < len(it.page) || it.more)
+}
+
+// Err returns the first error encountered while fetching pages.
+func (it *NoteIterator) Err() error { return it.err }
+
+func (it *NoteIterator) fetch() error {
+	q := url.Values{}
+	switch it.opts.Visibility {
+	case VisibilityPublic:
+		q.Set("public", "1")
+	case VisibilityPrivate:
+		q.Set("private", "1")
+	}
+	size := it.opts.PageSize
+	if rest := it.opts.Limit - it.seen; it.opts.Limit > 0 && (size == 0 || rest < size) {
+		size = rest
+	}
+	if size > 0 {
+		q.Set("limit", strconv.Itoa(size))
+	}
+	if it.cursor != "" {
+		q.Set("cursor", it.cursor)
+	} else if it.pageNum > 0 {
+		q.Set("page", strconv.Itoa(it.pageNum))
+	}
+	path := "/notes"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var raw json.RawMessage
+	if err := it.d.DoContext(it.ctx, "GET", path, nil, true, &raw); err != nil {
+		return err
+	}
+	it.page, it.idx, it.more = nil, 0, false
+	if len(raw) > 0 && raw[0] == '[' {
+		return json.Unmarshal(raw, &it.page)
+	}
+	var env struct {
+		Data       []Note `json:"data"`
+		NextCursor string `json:"next_cursor"`
+		NextPage   int    `json:"next_page"`
+	}
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return err
+	}
+	it.page = env.Data
+	it.cursor, it.pageNum = env.NextCursor, env.NextPage
+	it.more = (it.cursor != "" || it.pageNum > 0) && len(env.Data) > 0
+	return nil
+}
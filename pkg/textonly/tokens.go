@@ -0,0 +1,61 @@
+package textonly
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Token is a personal access token. Secret is only returned by Create; the
+// API never reveals it again.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	Secret     string     `json:"token,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type CreateTokenParams struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+	// ExpiresAt is nil for a token that never expires.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type TokensService struct {
+	d Doer
+}
+
+// List returns the personal access tokens of the authenticated user.
+func (s *TokensService) List(ctx context.Context) ([]Token, error) {
+	var out []Token
+	if err := s.d.DoContext(ctx, "GET", "/tokens", nil, true, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Current describes the token the client is authenticated with: its scopes
+// and expiry. ID and Name are empty for login sessions.
+func (s *TokensService) Current(ctx context.Context) (*Token, error) {
+	var t Token
+	if err := s.d.DoContext(ctx, "GET", "/auth/token", nil, true, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *TokensService) Create(ctx context.Context, params CreateTokenParams) (*Token, error) {
+	var t Token
+	if err := s.d.DoContext(ctx, "POST", "/tokens", params, true, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *TokensService) Revoke(ctx context.Context, id string) error {
+	return s.d.DoContext(ctx, "DELETE", "/tokens/"+url.PathEscape(id), nil, true, nil)
+}
@@ -0,0 +1,37 @@
+package textonly
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type User struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+	// Raw is the user exactly as the API sent it, including fields this
+	// package doesn't know about.
+	Raw json.RawMessage `json:"-"`
+}
+
+func (u *User) UnmarshalJSON(b []byte) error {
+	type user User
+	if err := json.Unmarshal(b, (*user)(u)); err != nil {
+		return err
+	}
+	u.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+type UsersService struct {
+	d Doer
+}
+
+// Me returns the user the client is authenticated as.
+func (s *UsersService) Me(ctx context.Context) (*User, error) {
+	var u User
+	if err := s.d.DoContext(ctx, "GET", "/me", nil, true, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -3,9 +3,118 @@ package ui
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 )
 
 func PrintJSON(v any) {
 	b, _ := json.MarshalIndent(v, "", "  ")
 	fmt.Println(string(b))
 }
+
+// JSONArray writes a JSON array one element at a time, producing the same
+// layout as PrintJSON without holding the whole slice in memory.
+type JSONArray struct {
+	w io.Writer
+	n int
+}
+
+func NewJSONArray(w io.Writer) *JSONArray { return &JSONArray{w: w} }
+
+func (a *JSONArray) Write(v any) error {
+	b, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if a.n == 0 {
+		sep = "[\n  "
+	}
+	a.n++
+	_, err = fmt.Fprintf(a.w, "%s%s", sep, b)
+	return err
+}
+
+// Close terminates the array; it must be called even if nothing was written.
+func (a *JSONArray) Close() error {
+	if a.n == 0 {
+		_, err := fmt.Fprintln(a.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprint(a.w, "\n]\n")
+	return err
+}
+
+// Progress draws a one-line progress bar on stderr. NewProgress returns nil
+// when stderr is not a terminal, and a nil *Progress ignores all calls.
+type Progress struct {
+	label string
+	total int64
+	n     int64
+	last  time.Time
+}
+
+func NewProgress(label string, total int64) *Progress {
+	if !IsTerminal(os.Stderr) {
+		return nil
+	}
+	return &Progress{label: label, total: total}
+}
+
+// IsTerminal reports whether f is attached to a terminal.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (p *Progress) Update(n int64) {
+	if p == nil {
+		return
+	}
+	p.n = n
+	if time.Since(p.last) < 100*time.Millisecond {
+		return
+	}
+	p.last = time.Now()
+	p.draw()
+}
+
+// Done draws the last reported state and ends the line.
+func (p *Progress) Done() {
+	if p == nil {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(os.Stderr)
+}
+
+func (p *Progress) draw() {
+	n := p.n
+	const width = 30
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%s %s", p.label, formatBytes(n))
+		return
+	}
+	if n > p.total {
+		n = p.total
+	}
+	filled := int(int64(width) * n / p.total)
+	fmt.Fprintf(os.Stderr, "\r%s [%s%s] %3d%% %s/%s", p.label,
+		strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+		100*n/p.total, formatBytes(n), formatBytes(p.total))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}